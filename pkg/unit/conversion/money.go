@@ -0,0 +1,33 @@
+package conversion
+
+import "math"
+
+// USDtoCents 金额转为整数美分, 四舍五入
+func USDtoCents(f float64) int64 {
+	return int64(math.Round(f * 100))
+}
+
+// CentsToUSD 整数美分转为金额
+func CentsToUSD(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// AccruedCents 周期共 totalDays 天、总额 totalCents 时, 前 elapsedDays 天累计分摊的美分 (四舍五入).
+// 任意区间 [a, b) 的分摊额取 AccruedCents(b) - AccruedCents(a), 各区间之和恰好等于总额
+func AccruedCents(totalCents int64, totalDays, elapsedDays int) int64 {
+	if totalDays <= 0 {
+		return 0
+	}
+	if elapsedDays < 0 {
+		elapsedDays = 0
+	}
+	if elapsedDays > totalDays {
+		elapsedDays = totalDays
+	}
+	numerator := totalCents * int64(elapsedDays)
+	denominator := int64(totalDays)
+	if numerator < 0 {
+		return -((-numerator*2 + denominator) / (2 * denominator))
+	}
+	return (numerator*2 + denominator) / (2 * denominator)
+}
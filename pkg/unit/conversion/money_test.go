@@ -0,0 +1,72 @@
+package conversion
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestUSDtoCents(t *testing.T) {
+	tests := map[float64]int64{
+		0:       0,
+		0.1:     10,
+		4.99:    499,
+		19.995:  2000,
+		1000.01: 100001,
+		-2.5:    -250,
+	}
+	for usd, want := range tests {
+		if got := USDtoCents(usd); got != want {
+			t.Errorf("USDtoCents(%v) = %d, want %d", usd, got, want)
+		}
+	}
+}
+
+func TestAccruedCents(t *testing.T) {
+	tests := []struct {
+		total, days, elapsed int
+		want                 int64
+	}{
+		{1000, 30, 0, 0},
+		{1000, 30, 30, 1000},
+		{1000, 30, 15, 500},
+		{1000, 30, 1, 33},
+		{1000, 30, 2, 67},
+		{1000, 30, -1, 0},
+		{1000, 30, 31, 1000},
+		{1000, 0, 10, 0},
+		{-1000, 30, 1, -33},
+	}
+	for _, tt := range tests {
+		if got := AccruedCents(int64(tt.total), tt.days, tt.elapsed); got != tt.want {
+			t.Errorf("AccruedCents(%d, %d, %d) = %d, want %d", tt.total, tt.days, tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+// TestAccruedCentsSplitSum 任意切分点得到的各段之和恰好等于总额, 且每段与按比例分摊的差不超过 1 美分
+func TestAccruedCentsSplitSum(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		totalCents := r.Int63n(10000000) - 1000
+		totalDays := 1 + r.Intn(1100)
+		cuts := []int{0, totalDays}
+		for n := r.Intn(40); n > 0; n-- {
+			cuts = append(cuts, r.Intn(totalDays+1))
+		}
+		sort.Ints(cuts)
+
+		var sum int64
+		for j := 1; j < len(cuts); j++ {
+			piece := AccruedCents(totalCents, totalDays, cuts[j]) - AccruedCents(totalCents, totalDays, cuts[j-1])
+			exact := float64(totalCents) * float64(cuts[j]-cuts[j-1]) / float64(totalDays)
+			if diff := float64(piece) - exact; diff > 1 || diff < -1 {
+				t.Fatalf("total %d days %d piece [%d, %d) = %d, want about %.2f", totalCents, totalDays, cuts[j-1], cuts[j], piece, exact)
+			}
+			sum += piece
+		}
+		if sum != totalCents {
+			t.Fatalf("total %d days %d cuts %v: pieces sum to %d", totalCents, totalDays, cuts, sum)
+		}
+	}
+}
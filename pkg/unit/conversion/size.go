@@ -1,5 +1,13 @@
 package conversion
 
+func BtoGB(f float64) float64 {
+	return f / 1024 / 1024 / 1024
+}
+
+func KBtoGB(f float64) float64 {
+	return f / 1024 / 1024
+}
+
 func MBtoGB(f float64) float64 {
 	return f / 1024
 }
@@ -7,3 +15,11 @@ func MBtoGB(f float64) float64 {
 func TBtoGB(f float64) float64 {
 	return f * 1024
 }
+
+func PBtoGB(f float64) float64 {
+	return f * 1024 * 1024
+}
+
+func GBtoB(f float64) float64 {
+	return f * 1024 * 1024 * 1024
+}
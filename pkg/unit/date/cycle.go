@@ -0,0 +1,117 @@
+package date
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BillingCycle 付费周期, 与面板显示语言无关的规范值
+type BillingCycle string
+
+const (
+	CycleUnknown      BillingCycle = ""
+	CycleMonthly      BillingCycle = "month"
+	CycleQuarterly    BillingCycle = "quarter"
+	CycleSemiAnnually BillingCycle = "semiannual"
+	CycleAnnually     BillingCycle = "year"
+	CycleBiennially   BillingCycle = "biennial"
+	CycleTriennially  BillingCycle = "triennial"
+	CycleOneTime      BillingCycle = "onetime"
+)
+
+// RecurringCycles 所有周期性付费的周期, 按周期长度排序
+var RecurringCycles = []BillingCycle{
+	CycleMonthly,
+	CycleQuarterly,
+	CycleSemiAnnually,
+	CycleAnnually,
+	CycleBiennially,
+	CycleTriennially,
+}
+
+// ErrNotRecurring 一次性付费或未知周期没有付费周期
+var ErrNotRecurring = errors.New("billing cycle is not recurring")
+
+// Months 周期包含的月数, 一次性付费及未知周期返回 0
+func (c BillingCycle) Months() int {
+	switch c {
+	case CycleMonthly:
+		return 1
+	case CycleQuarterly:
+		return 3
+	case CycleSemiAnnually:
+		return 6
+	case CycleAnnually:
+		return 12
+	case CycleBiennially:
+		return 24
+	case CycleTriennially:
+		return 36
+	}
+	return 0
+}
+
+// Recurring 是否为周期性付费
+func (c BillingCycle) Recurring() bool {
+	return c.Months() > 0
+}
+
+// AddTo 返回 t 之后 n 个周期的时间 (n 为负数时向前), 月末日期按目标月份天数截断: 01-31 + 1 月 -> 02-28
+func (c BillingCycle) AddTo(t time.Time, n int) time.Time {
+	return addMonths(t, c.Months()*n)
+}
+
+// PeriodStart 根据周期结束日期计算起始日期, 固定格式 2006-01-02
+func (c BillingCycle) PeriodStart(dateEnd string) (string, error) {
+	if !c.Recurring() {
+		return "", fmt.Errorf("%w: %q", ErrNotRecurring, string(c))
+	}
+	t, err := time.Parse("2006-01-02", dateEnd)
+	if err != nil {
+		return "", err
+	}
+	return c.AddTo(t, -1).Format("2006-01-02"), nil
+}
+
+// PeriodEnd 根据周期起始日期计算结束日期, 固定格式 2006-01-02
+func (c BillingCycle) PeriodEnd(dateStart string) (string, error) {
+	if !c.Recurring() {
+		return "", fmt.Errorf("%w: %q", ErrNotRecurring, string(c))
+	}
+	t, err := time.Parse("2006-01-02", dateStart)
+	if err != nil {
+		return "", err
+	}
+	return c.AddTo(t, 1).Format("2006-01-02"), nil
+}
+
+// PeriodAt 以 anchor (如续费日期) 为周期边界, 返回 t 所在周期的起止时间 [start, end)
+func (c BillingCycle) PeriodAt(anchor, t time.Time) (time.Time, time.Time, error) {
+	if !c.Recurring() {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %q", ErrNotRecurring, string(c))
+	}
+	months := (t.Year()-anchor.Year())*12 + int(t.Month()-anchor.Month())
+	n := months / c.Months()
+	if months < 0 && months%c.Months() != 0 {
+		n--
+	}
+	// 月末日期截断可能使估算偏差一个周期, 始终从 anchor 计算避免误差累积
+	for c.AddTo(anchor, n).After(t) {
+		n--
+	}
+	for !c.AddTo(anchor, n+1).After(t) {
+		n++
+	}
+	return c.AddTo(anchor, n), c.AddTo(anchor, n+1), nil
+}
+
+// addMonths 按月份加减, 日期超出目标月份天数时取目标月份最后一天
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,151 @@
+package date
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestBillingCycleAddTo(t *testing.T) {
+	tests := []struct {
+		cycle BillingCycle
+		from  string
+		n     int
+		want  string
+	}{
+		{CycleMonthly, "2023-01-15", 1, "2023-02-15"},
+		{CycleMonthly, "2023-01-31", 1, "2023-02-28"},
+		{CycleMonthly, "2024-01-31", 1, "2024-02-29"},
+		{CycleMonthly, "2023-03-31", -1, "2023-02-28"},
+		{CycleMonthly, "2023-03-31", -2, "2023-01-31"},
+		{CycleMonthly, "2023-03-31", -3, "2022-12-31"},
+		{CycleMonthly, "2023-12-31", 2, "2024-02-29"},
+		{CycleQuarterly, "2023-11-30", 1, "2024-02-29"},
+		{CycleQuarterly, "2023-05-31", -1, "2023-02-28"},
+		{CycleSemiAnnually, "2023-08-31", 1, "2024-02-29"},
+		{CycleSemiAnnually, "2023-03-31", -1, "2022-09-30"},
+		{CycleAnnually, "2024-02-29", 1, "2025-02-28"},
+		{CycleAnnually, "2024-02-29", -1, "2023-02-28"},
+		{CycleAnnually, "2024-02-29", 4, "2028-02-29"},
+		{CycleBiennially, "2022-02-28", 1, "2024-02-28"},
+		{CycleBiennially, "2024-02-29", -1, "2022-02-28"},
+		{CycleTriennially, "2021-02-28", 1, "2024-02-28"},
+		{CycleTriennially, "2024-02-29", 1, "2027-02-28"},
+		{CycleOneTime, "2023-01-31", 1, "2023-01-31"},
+		{CycleUnknown, "2023-01-31", -1, "2023-01-31"},
+	}
+	for _, tt := range tests {
+		if got := tt.cycle.AddTo(day(tt.from), tt.n).Format("2006-01-02"); got != tt.want {
+			t.Errorf("%q.AddTo(%s, %d) = %s, want %s", tt.cycle, tt.from, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestBillingCyclePeriodAt(t *testing.T) {
+	tests := []struct {
+		cycle  BillingCycle
+		anchor string
+		at     string
+		start  string
+		end    string
+	}{
+		{CycleMonthly, "2023-03-31", "2023-03-15", "2023-02-28", "2023-03-31"},
+		{CycleMonthly, "2023-03-31", "2023-03-31", "2023-03-31", "2023-04-30"},
+		{CycleMonthly, "2023-03-31", "2022-12-31", "2022-12-31", "2023-01-31"},
+		{CycleMonthly, "2023-03-31", "2022-12-30", "2022-11-30", "2022-12-31"},
+		{CycleMonthly, "2023-01-31", "2023-06-01", "2023-05-31", "2023-06-30"},
+		{CycleQuarterly, "2023-01-31", "2023-05-01", "2023-04-30", "2023-07-31"},
+		{CycleSemiAnnually, "2023-08-31", "2024-01-01", "2023-08-31", "2024-02-29"},
+		{CycleAnnually, "2024-02-29", "2025-03-01", "2025-02-28", "2026-02-28"},
+		{CycleAnnually, "2024-02-29", "2023-03-01", "2023-02-28", "2024-02-29"},
+		{CycleBiennially, "2022-10-01", "2020-10-01", "2020-10-01", "2022-10-01"},
+		{CycleTriennially, "2022-10-01", "2027-01-01", "2025-10-01", "2028-10-01"},
+	}
+	for _, tt := range tests {
+		start, end, err := tt.cycle.PeriodAt(day(tt.anchor), day(tt.at))
+		if err != nil {
+			t.Errorf("%q.PeriodAt(%s, %s) error: %v", tt.cycle, tt.anchor, tt.at, err)
+			continue
+		}
+		if got := start.Format("2006-01-02"); got != tt.start {
+			t.Errorf("%q.PeriodAt(%s, %s) start = %s, want %s", tt.cycle, tt.anchor, tt.at, got, tt.start)
+		}
+		if got := end.Format("2006-01-02"); got != tt.end {
+			t.Errorf("%q.PeriodAt(%s, %s) end = %s, want %s", tt.cycle, tt.anchor, tt.at, got, tt.end)
+		}
+	}
+	for _, cycle := range []BillingCycle{CycleOneTime, CycleUnknown} {
+		if _, _, err := cycle.PeriodAt(day("2023-01-01"), day("2023-06-01")); !errors.Is(err, ErrNotRecurring) {
+			t.Errorf("%q.PeriodAt error = %v, want ErrNotRecurring", cycle, err)
+		}
+	}
+}
+
+func TestGetDateSubPeriodUnit(t *testing.T) {
+	tests := []struct {
+		start string
+		end   string
+		want  BillingCycle
+	}{
+		{"2023-01-15", "2023-02-15", CycleMonthly},
+		{"2023-01-31", "2023-02-28", CycleMonthly},
+		{"2023-02-28", "2023-03-31", CycleMonthly},
+		{"2023-01-01", "2023-04-01", CycleQuarterly},
+		{"2023-11-30", "2024-02-29", CycleQuarterly},
+		{"2023-01-01", "2023-07-01", CycleSemiAnnually},
+		{"2022-10-01", "2023-10-01", CycleAnnually},
+		{"2024-02-29", "2025-02-28", CycleAnnually},
+		{"2022-10-01", "2024-10-01", CycleBiennially},
+		{"2022-10-01", "2025-10-01", CycleTriennially},
+	}
+	for _, tt := range tests {
+		got, err := GetDateSubPeriodUnit(tt.start, tt.end)
+		if err != nil {
+			t.Errorf("GetDateSubPeriodUnit(%s, %s) error: %v", tt.start, tt.end, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("GetDateSubPeriodUnit(%s, %s) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+	for _, tt := range [][2]string{
+		{"2023-01-01", "2023-01-31"},
+		{"2023-01-01", "2023-05-01"},
+		{"2023-02-01", "2023-01-01"},
+		{"2023-01-01", "invalid"},
+	} {
+		if got, err := GetDateSubPeriodUnit(tt[0], tt[1]); err == nil {
+			t.Errorf("GetDateSubPeriodUnit(%s, %s) = %q, want error", tt[0], tt[1], got)
+		}
+	}
+}
+
+func TestBillingCyclePeriodStartEnd(t *testing.T) {
+	for _, cycle := range RecurringCycles {
+		start, err := cycle.PeriodStart("2023-10-01")
+		if err != nil {
+			t.Fatalf("%q.PeriodStart error: %v", cycle, err)
+		}
+		end, err := cycle.PeriodEnd(start)
+		if err != nil {
+			t.Fatalf("%q.PeriodEnd error: %v", cycle, err)
+		}
+		if end != "2023-10-01" {
+			t.Errorf("%q.PeriodEnd(%s) = %s, want 2023-10-01", cycle, start, end)
+		}
+		if got, _ := GetDateSubPeriodUnit(start, end); got != cycle {
+			t.Errorf("GetDateSubPeriodUnit(%s, %s) = %q, want %q", start, end, got, cycle)
+		}
+	}
+	if _, err := CycleOneTime.PeriodStart("2023-10-01"); !errors.Is(err, ErrNotRecurring) {
+		t.Errorf("PeriodStart error = %v, want ErrNotRecurring", err)
+	}
+}
@@ -59,25 +59,24 @@ func ChangeDateLayout(oldTime string) (string, error) {
 	return t.Format("2006-01-02"), nil
 }
 
-// GetDateSubPeriodUnit 两个日期之间相差单位. "2022-10-01", "2023-10-1" -> year
-func GetDateSubPeriodUnit(dateStart, dateEnd string) (string, error) {
+// GetDateSubPeriodUnit 两个日期之间相差的付费周期. "2022-10-01", "2023-10-01" -> year
+// 月末日期允许按月份天数截断的误差: "2023-01-31", "2023-02-28" -> month
+func GetDateSubPeriodUnit(dateStart, dateEnd string) (BillingCycle, error) {
 	layout := "2006-01-02"
 	t0, err := time.Parse(layout, dateStart)
 	if err != nil {
-		return "", err
+		return CycleUnknown, err
 	}
 	t1, err := time.Parse(layout, dateEnd)
 	if err != nil {
-		return "", err
+		return CycleUnknown, err
 	}
-	dateDiffer := t1.Sub(t0)
-	if dateDiffer > time.Hour*24*360 {
-		return "year", nil
+	for _, cycle := range RecurringCycles {
+		if cycle.AddTo(t0, 1).Equal(t1) || cycle.AddTo(t1, -1).Equal(t0) {
+			return cycle, nil
+		}
 	}
-	if dateDiffer >= time.Hour*24*28 {
-		return "month", nil
-	}
-	return "", fmt.Errorf("unrecognized time period")
+	return CycleUnknown, fmt.Errorf("unrecognized time period %s - %s", dateStart, dateEnd)
 }
 
 // GetDateSubPeriodDays 两个时间之间相差天数.
@@ -110,10 +109,15 @@ func GetDateRangeYearToMonth(dateStart, dateEnd string) ([]string, error) {
 	}
 
 	dateRangeMonth = append(dateRangeMonth, dateStart)
-	for d := t0.AddDate(0, 1, 0); d.After(t1) == false; d = d.AddDate(0, 1, 0) {
+	// 从月初开始累加, 避免 29-31 日加一个月后溢出到下下个月而漏掉月份
+	first := time.Date(t0.Year(), t0.Month(), 1, 0, 0, 0, 0, t0.Location())
+	for d := first.AddDate(0, 1, 0); d.After(t1) == false; d = d.AddDate(0, 1, 0) {
 		dateRangeMonth = append(dateRangeMonth, fmt.Sprintf("%s%s", d.Format("2006-01"), "-01"))
 	}
-	dateRangeMonth = append(dateRangeMonth, dateEnd)
+	// dateEnd 为月初时已作为最后一个月份边界, 不再重复添加
+	if dateRangeMonth[len(dateRangeMonth)-1] != dateEnd {
+		dateRangeMonth = append(dateRangeMonth, dateEnd)
+	}
 	return dateRangeMonth, nil
 }
 
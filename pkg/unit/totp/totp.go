@@ -0,0 +1,55 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	period = 30 // 时间步长 second
+	digits = 6
+)
+
+// GenerateCode 按 RFC 6238 生成 TOTP 验证码 (HMAC-SHA1, 30 秒步长, 6 位), secret 为 base32 编码
+func GenerateCode(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(t.Unix()/period)), nil
+}
+
+// decodeSecret base32 解码, 兼容小写、空格及省略的 "=" 填充
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Join(strings.Fields(secret), ""))
+	secret = strings.TrimRight(secret, "=")
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("Failed decode totp secret: empty secret")
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("Failed decode totp secret: %s", err.Error())
+	}
+	return key, nil
+}
+
+// hotp RFC 4226 HOTP, 动态截断后取低 digits 位
+func hotp(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
@@ -0,0 +1,51 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 的 SHA-1 测试向量, 密钥为 ASCII "12345678901234567890", 取 8 位验证码的低 6 位
+func TestGenerateCodeRFC6238(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		code, err := GenerateCode(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("GenerateCode(%d) error: %v", tt.unix, err)
+		}
+		if code != tt.code {
+			t.Errorf("GenerateCode(%d) = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestGenerateCodeSecretFormat(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	want, _ := GenerateCode("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", now)
+	for _, secret := range []string{
+		"gezdgnbvgy3tqojqgezdgnbvgy3tqojq",
+		"GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ",
+		"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ====",
+	} {
+		code, err := GenerateCode(secret, now)
+		if err != nil || code != want {
+			t.Errorf("GenerateCode(%q) = %s, %v, want %s", secret, code, err, want)
+		}
+	}
+	for _, secret := range []string{"", "   ", "not-base32!"} {
+		if _, err := GenerateCode(secret, now); err == nil {
+			t.Errorf("GenerateCode(%q) expected error", secret)
+		}
+	}
+}
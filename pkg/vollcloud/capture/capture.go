@@ -0,0 +1,128 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/spf13/viper"
+
+	"vollcloud-exporter/pkg/unit/url_parse"
+	"vollcloud-exporter/pkg/vollcloud/grab"
+	vclogin "vollcloud-exporter/pkg/vollcloud/login"
+)
+
+// page 抓取到的原始页面及保存的文件名 (与 docs/example 一致)
+type page struct {
+	File string
+	Html []byte
+}
+
+// Capture 登录账号并抓取各页面, 脱敏后按 docs/example 的文件名写入 dir
+func Capture(account vclogin.Account, dir string) error {
+	vcLogin := vclogin.NewLogin(account)
+	if _, err := vcLogin.Login(); err != nil {
+		return err
+	}
+	httpClient := vcLogin.HttpClient
+	redactor := NewRedactor()
+	redactor.AddUsername(account.Username)
+
+	var pages []page
+	clientarea, err := fetch(httpClient, viper.GetString("vollcloud.clientarea.url"))
+	if err != nil {
+		return err
+	}
+	pages = append(pages, page{File: "login.html", Html: clientarea})
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(clientarea))
+	if err != nil {
+		return err
+	}
+	redactor.AddUsername(doc.Find(".nav-item.dropdown.account .nav-link.dropdown-toggle").Text())
+
+	services, err := fetch(httpClient, viper.GetString("vollcloud.services.url"))
+	if err != nil {
+		return err
+	}
+	pages = append(pages, page{File: "services.html", Html: services})
+	vsServices := grab.NewServices(http.Client{}, account.Name)
+	if vsServices.Doc, err = goquery.NewDocumentFromReader(bytes.NewReader(services)); err != nil {
+		return err
+	}
+	if err := vsServices.GetProductIdUrls(); err != nil {
+		return err
+	}
+	vsServices.Doc.Find("#tableServicesList tbody tr .text-black-50").Each(func(i int, s *goquery.Selection) {
+		redactor.AddHostname(s.Text())
+	})
+
+	cost, err := fetch(httpClient, viper.GetString("vollcloud.cost.url"))
+	if err != nil {
+		return err
+	}
+	pages = append(pages, page{File: "cost.html", Html: cost})
+	costs := grab.NewCost(http.Client{}, account.Name)
+	if costs.Doc, err = goquery.NewDocumentFromReader(bytes.NewReader(cost)); err != nil {
+		return err
+	}
+	if err := costs.GetRenewalEntries(); err != nil {
+		return err
+	}
+	for _, entry := range costs.Entries {
+		redactor.AddHostname(entry.Description)
+		redactor.AddProductId(entry.ProductId)
+	}
+
+	for i, idUrl := range vsServices.IdUrls {
+		productId, err := url_parse.GetParameId(idUrl, "id")
+		if err != nil {
+			return err
+		}
+		redactor.AddProductId(productId)
+		url := fmt.Sprintf("%s%s&language=english", viper.GetString("vollcloud.productdetails.url"), idUrl)
+		productdetails, err := fetch(httpClient, url)
+		if err != nil {
+			return err
+		}
+		file := "productdetails.html"
+		if i > 0 {
+			file = fmt.Sprintf("productdetails-%d.html", i+1)
+		}
+		pages = append(pages, page{File: file, Html: productdetails})
+		productDoc, err := goquery.NewDocumentFromReader(bytes.NewReader(productdetails))
+		if err != nil {
+			return err
+		}
+		redactor.AddHostname(productDoc.Find("#solus-hostname").Text())
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Failed Capture mkdir: %s", err.Error())
+	}
+	for _, p := range pages {
+		path := filepath.Join(dir, p.File)
+		if err := os.WriteFile(path, redactor.Redact(p.Html), 0644); err != nil {
+			return fmt.Errorf("Failed Capture write %s: %s", path, err.Error())
+		}
+		log.Println("Info Capture write: ", path)
+	}
+	return nil
+}
+
+// fetch GET 页面原始 html
+func fetch(httpClient *http.Client, url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("Failed Capture Get %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Failed Capture Get %s: %w", url, &grab.ErrHTTPStatus{Page: "capture", Code: resp.StatusCode})
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,178 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"vollcloud-exporter/pkg/vollcloud/grab"
+)
+
+// goldenAccount 解析 fixture 时使用的 account 标签
+const goldenAccount = "golden"
+
+// goldenNow 展开付费周期时使用的固定时间, 早于 fixture 中的到期时间, 使结果不随运行日期变化
+var goldenNow = time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// goldenCost 续费页面的解析结果
+type goldenCost struct {
+	Entries   []grab.RenewalEntry
+	CostInfos []grab.CostInfo
+}
+
+// goldenResult fixture 的解析结果, 解析失败时记录错误信息
+type goldenResult struct {
+	Result interface{} `json:",omitempty"`
+	Error  string      `json:",omitempty"`
+}
+
+// parseFixture 按文件名选择解析器: services / productdetails / cost, 其它页面返回 false
+func parseFixture(path string) (goldenResult, bool, error) {
+	html, err := os.ReadFile(path)
+	if err != nil {
+		return goldenResult{}, false, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return goldenResult{}, false, err
+	}
+	name := filepath.Base(path)
+	switch {
+	case strings.HasPrefix(name, "services"):
+		vsServices := grab.NewServices(http.Client{}, goldenAccount)
+		vsServices.Doc = doc
+		if err := vsServices.GetProductIdUrls(); err != nil {
+			return goldenResult{Error: err.Error()}, true, nil
+		}
+		return goldenResult{Result: vsServices.IdUrls}, true, nil
+	case strings.HasPrefix(name, "productdetails"):
+		vsProductdetails := grab.NewProductdetails(http.Client{}, goldenAccount)
+		vsProductdetails.Doc = doc
+		if err := vsProductdetails.CreateStats(); err != nil {
+			return goldenResult{Error: err.Error()}, true, nil
+		}
+		return goldenResult{Result: vsProductdetails.Stats}, true, nil
+	case strings.HasPrefix(name, "cost"):
+		costs := grab.NewCost(http.Client{}, goldenAccount)
+		costs.Doc = doc
+		if err := costs.GetRenewalEntries(); err != nil {
+			return goldenResult{Error: err.Error()}, true, nil
+		}
+		return goldenResult{Result: goldenCost{
+			Entries:   costs.Entries,
+			CostInfos: grab.ExpandCostInfos(costs.Entries, goldenNow),
+		}}, true, nil
+	}
+	return goldenResult{}, false, nil
+}
+
+// CheckGolden 解析 dir 中所有 fixture, 与 dir/golden/*.json 对比并返回差异; update 时重写 golden 文件.
+// 供 golden 子命令使用, go test 中由 TestGolden 逐个 fixture 检查
+func CheckGolden(dir string, update bool) ([]string, error) {
+	fixtures, err := listFixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []string
+	for _, fixture := range fixtures {
+		diff, _, err := checkFixture(fixture, update)
+		if err != nil {
+			return diffs, err
+		}
+		if len(diff) != 0 {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
+
+// listFixtures dir 中所有 html fixture, 按文件名排序
+func listFixtures(dir string) ([]string, error) {
+	fixtures, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(fixtures)
+	return fixtures, nil
+}
+
+// checkFixture 解析单个 fixture 并与 golden/<name>.json 对比, 返回差异 (一致时为空);
+// 没有对应解析器的页面 (如 login.html) 返回 ok=false
+func checkFixture(fixture string, update bool) (diff string, ok bool, err error) {
+	result, ok, err := parseFixture(fixture)
+	if err != nil {
+		return "", ok, fmt.Errorf("Failed CheckGolden parse %s: %s", fixture, err.Error())
+	}
+	if !ok {
+		return "", false, nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return "", true, err
+	}
+	actual := buf.Bytes()
+	goldenDir := filepath.Join(filepath.Dir(fixture), "golden")
+	goldenFile := filepath.Join(goldenDir, strings.TrimSuffix(filepath.Base(fixture), ".html")+".json")
+	if update {
+		if err := os.MkdirAll(goldenDir, 0755); err != nil {
+			return "", true, err
+		}
+		return "", true, os.WriteFile(goldenFile, actual, 0644)
+	}
+	expected, err := os.ReadFile(goldenFile)
+	if err != nil {
+		return fmt.Sprintf("%s: %s, run golden with --golden.update to create it", fixture, err.Error()), true, nil
+	}
+	if !bytes.Equal(expected, actual) {
+		return fmt.Sprintf("--- %s\n+++ %s\n%s", goldenFile, fixture, diffLines(string(expected), string(actual))), true, nil
+	}
+	return "", true, nil
+}
+
+// diffLines 基于最长公共子序列的逐行对比, 只输出不同的行
+func diffLines(expected, actual string) string {
+	a := strings.Split(expected, "\n")
+	b := strings.Split(actual, "\n")
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Fprintf(&out, "+%d: %s\n", j+1, b[j])
+			j++
+		default:
+			fmt.Fprintf(&out, "-%d: %s\n", i+1, a[i])
+			i++
+		}
+	}
+	return out.String()
+}
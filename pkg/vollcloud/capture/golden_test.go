@@ -0,0 +1,55 @@
+package capture
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite docs/example/golden/*.json from the current parse results")
+
+// TestGolden 逐个解析 docs/example 中的 fixture 并与 golden 文件对比, go test ./pkg/vollcloud/capture -update 重写 golden 文件
+func TestGolden(t *testing.T) {
+	fixtures, err := listFixtures("../../../docs/example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := map[string]bool{}
+	for _, fixture := range fixtures {
+		name := filepath.Base(fixture)
+		t.Run(name, func(t *testing.T) {
+			diff, ok, err := checkFixture(fixture, *update)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				t.Skip("no parser for this page")
+			}
+			parsed[name] = true
+			if len(diff) != 0 {
+				t.Errorf("golden mismatch, run go test ./pkg/vollcloud/capture -update if the change is intended\n%s", diff)
+			}
+		})
+	}
+	for _, name := range []string{"services.html", "productdetails.html", "cost.html"} {
+		if !parsed[name] {
+			t.Errorf("fixture %s was not checked", name)
+		}
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		expected, actual, want string
+	}{
+		{"a\nb\nc", "a\nb\nc", ""},
+		{"a\nb\nc", "a\nx\nc", "+2: x\n-2: b\n"},
+		{"a\nc", "a\nb\nc", "+2: b\n"},
+		{"a\nb\nc", "a\nc", "-2: b\n"},
+	}
+	for _, tt := range tests {
+		if got := diffLines(tt.expected, tt.actual); got != tt.want {
+			t.Errorf("diffLines(%q, %q) = %q, want %q", tt.expected, tt.actual, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package capture
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	ipv4Regexp = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Regexp = regexp.MustCompile(`\b[0-9a-fA-F]{1,4}(?::[0-9a-fA-F]{0,4}){3,7}\b`)
+	// productIdRegexp url 参数中的产品 ID, 如 "action=productdetails&amp;id=3266", "sid=3266"
+	productIdRegexp = regexp.MustCompile(`\b(s?id=)([0-9]+)\b`)
+	inputRegexp     = regexp.MustCompile(`(?i)<input[^>]*>`)
+	valueRegexp     = regexp.MustCompile(`(?i)(value=")[^"]*(")`)
+)
+
+// Redactor 抓取页面脱敏: 用户名、IP、主机名、产品 ID 及表单中的 token/密码
+type Redactor struct {
+	strings    map[string]string
+	productIds map[string]string
+	hostnames  int
+}
+
+func NewRedactor() *Redactor {
+	return &Redactor{
+		strings:    map[string]string{},
+		productIds: map[string]string{},
+	}
+}
+
+// AddUsername 用户名 / 页头显示名替换为 redacted
+func (r *Redactor) AddUsername(username string) {
+	r.addString(username, "redacted")
+}
+
+// AddHostname 主机名按出现顺序替换为 host-N
+func (r *Redactor) AddHostname(hostname string) {
+	hostname = strings.TrimSpace(hostname)
+	if _, ok := r.strings[hostname]; ok || len(hostname) == 0 {
+		return
+	}
+	r.hostnames++
+	r.addString(hostname, fmt.Sprintf("host-%d", r.hostnames))
+}
+
+// AddProductId 产品 ID 按出现顺序替换为 1001 起的假 ID, 各页面保持一致以便关联
+func (r *Redactor) AddProductId(id string) {
+	if len(id) == 0 {
+		return
+	}
+	if _, ok := r.productIds[id]; ok {
+		return
+	}
+	r.productIds[id] = fmt.Sprintf("%d", 1001+len(r.productIds))
+}
+
+func (r *Redactor) addString(secret, replacement string) {
+	secret = strings.TrimSpace(secret)
+	if len(secret) == 0 {
+		return
+	}
+	r.strings[secret] = replacement
+}
+
+// Redact 对页面做脱敏替换
+func (r *Redactor) Redact(html []byte) []byte {
+	s := string(html)
+	s = inputRegexp.ReplaceAllStringFunc(s, func(input string) string {
+		lower := strings.ToLower(input)
+		if strings.Contains(lower, `type="password"`) || strings.Contains(lower, `name="token"`) || strings.Contains(lower, `name="username"`) {
+			return valueRegexp.ReplaceAllString(input, "${1}redacted${2}")
+		}
+		return input
+	})
+
+	// 长字符串优先替换, 避免主机名中包含的用户名被先替换
+	secrets := make([]string, 0, len(r.strings))
+	for secret := range r.strings {
+		secrets = append(secrets, secret)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, r.strings[secret])
+	}
+
+	s = ipv6Regexp.ReplaceAllString(s, "2001:db8::3")
+	s = ipv4Regexp.ReplaceAllString(s, "3.3.3.3")
+	// 只替换 id= / sid= 参数, 避免与产品 ID 相同的容量、价格、日期被改写
+	s = productIdRegexp.ReplaceAllStringFunc(s, func(param string) string {
+		match := productIdRegexp.FindStringSubmatch(param)
+		if id, ok := r.productIds[match[2]]; ok {
+			return match[1] + id
+		}
+		return param
+	})
+	return []byte(s)
+}
@@ -0,0 +1,52 @@
+package capture
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	redactor := NewRedactor()
+	redactor.AddUsername("doumeng")
+	redactor.AddHostname("cn-hk-doumeng.example.com")
+	redactor.AddProductId("2048")
+	html := `<a href="/clientarea.php?action=productdetails&amp;id=2048">cn-hk-doumeng.example.com</a>
+<a href="https://vollcloud.com/index.php?m=renewal&amp;action=renew&amp;sid=2048">续费</a>
+<td>Memory</td><td>2048 MB</td><td>$2048.00 USD</td><td>2048-01-02</td>
+<span>doumeng</span><td>103.1.2.3</td><td>2400:8a20:112:1::a</td>
+<input type="hidden" name="token" value="abc123"><input type="password" value="root-pass">
+<input type="hidden" name="username" value="doumeng"><div id="solus-hostname"></div>`
+
+	got := string(redactor.Redact([]byte(html)))
+	for _, want := range []string{
+		"action=productdetails&amp;id=1001",
+		"sid=1001",
+		"2048 MB", "$2048.00 USD", "2048-01-02",
+		"<span>redacted</span>",
+		">host-1</a>",
+		"3.3.3.3", "2001:db8::3",
+		`name="token" value="redacted"`,
+		`type="password" value="redacted"`,
+		`id="solus-hostname"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Redact output missing %q:\n%s", want, got)
+		}
+	}
+	for _, secret := range []string{"doumeng", "103.1.2.3", "2400:8a20", "abc123", "root-pass", "id=2048"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("Redact output still contains %q:\n%s", secret, got)
+		}
+	}
+}
+
+func TestRedactProductIdsStable(t *testing.T) {
+	redactor := NewRedactor()
+	redactor.AddProductId("3266")
+	redactor.AddProductId("3330")
+	redactor.AddProductId("3266")
+	got := string(redactor.Redact([]byte("id=3330 sid=3266 id=9999 pid=3266")))
+	if want := "id=1002 sid=1001 id=9999 pid=3266"; got != want {
+		t.Errorf("Redact = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,313 @@
+package fakepanel
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"vollcloud-exporter/pkg/unit/totp"
+)
+
+const (
+	sessionCookie = "WHMCSFakeSession"
+	csrfToken     = "fakepanel-csrf-token"
+)
+
+// 页面名称, 与 metrics page 标签一致
+const (
+	PageLogin          = "login"
+	PageClientarea     = "clientarea"
+	PageServices       = "services"
+	PageProductdetails = "productdetails"
+	PageCost           = "cost"
+)
+
+// fixtureFiles 页面对应 docs/example 中的抓取样例; 登录后页面复用 login.html (已登录的页头)
+var fixtureFiles = map[string]string{
+	PageClientarea:     "login.html",
+	PageServices:       "services.html",
+	PageProductdetails: "productdetails.html",
+	PageCost:           "cost.html",
+}
+
+// Fault 注入到指定页面的异常: 延迟响应和/或返回指定状态码
+type Fault struct {
+	Status int
+	Delay  time.Duration
+}
+
+// Server 本地模拟的 vollcloud WHMCS 面板, 基于 httptest, 用于离线测试完整抓取流程
+type Server struct {
+	*httptest.Server
+	Username string
+	Password string
+	// TotpSecret 非空时登录需要两步验证, 密码正确后返回 WHMCS 两步验证表单 (input name="key")
+	TotpSecret string
+
+	fixtures map[string][]byte
+
+	mutex      sync.Mutex
+	sessions   map[string]bool
+	pending    map[string]bool // 密码正确、等待两步验证的会话
+	faults     map[string]Fault
+	loginCount int
+}
+
+// NewServer 从 fixtureDir (如 docs/example) 读取页面样例并启动模拟面板
+func NewServer(fixtureDir, username, password string) (*Server, error) {
+	fixtures := map[string][]byte{}
+	for page, file := range fixtureFiles {
+		data, err := os.ReadFile(filepath.Join(fixtureDir, file))
+		if err != nil {
+			return nil, fmt.Errorf("Failed fakepanel read fixture: %s", err.Error())
+		}
+		fixtures[page] = data
+	}
+	s := &Server{
+		Username: username,
+		Password: password,
+		fixtures: fixtures,
+		sessions: map[string]bool{},
+		pending:  map[string]bool{},
+		faults:   map[string]Fault{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s, nil
+}
+
+// Configure 将 viper 中 vollcloud 各页面 url 指向模拟面板
+func (s *Server) Configure() {
+	viper.Set("vollcloud.login.url", s.URL+"/index.php/login")
+	viper.Set("vollcloud.services.url", s.URL+"/clientarea.php?action=services")
+	viper.Set("vollcloud.productdetails.url", s.URL+"/")
+	viper.Set("vollcloud.clientarea.url", s.URL+"/clientarea.php")
+	viper.Set("vollcloud.cost.url", s.URL+"/index.php?m=renewal")
+}
+
+// ExpireSessions 使所有已登录会话失效, 模拟面板会话过期
+func (s *Server) ExpireSessions() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sessions = map[string]bool{}
+}
+
+// SetFault 为页面注入异常, Fault{} 清除异常
+func (s *Server) SetFault(page string, fault Fault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if fault == (Fault{}) {
+		delete(s.faults, page)
+		return
+	}
+	s.faults[page] = fault
+}
+
+// SetFixture 替换页面返回的内容, 用于构造样例中不存在的页面结构
+func (s *Server) SetFixture(page string, data []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fixtures[page] = data
+}
+
+// LoginCount 成功登录的次数
+func (s *Server) LoginCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.loginCount
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	page := route(r)
+	if len(page) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mutex.Lock()
+	fault := s.faults[page]
+	s.mutex.Unlock()
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fault.Status != 0 {
+		http.Error(w, http.StatusText(fault.Status), fault.Status)
+		return
+	}
+
+	if page == PageLogin {
+		s.serveLogin(w, r)
+		return
+	}
+	if !s.loggedIn(r) {
+		// WHMCS 未登录时访问用户中心页面会显示登录表单
+		writeHTML(w, loginPage(""))
+		return
+	}
+	writeHTML(w, s.fixture(page))
+}
+
+func (s *Server) fixture(page string) []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.fixtures[page]
+}
+
+// route 根据路径及参数判断页面; productdetails url 由配置拼接, 可能出现 "//clientarea.php"
+func route(r *http.Request) string {
+	query := r.URL.Query()
+	switch path.Clean("/" + r.URL.Path) {
+	case "/index.php/login":
+		return PageLogin
+	case "/index.php":
+		if query.Get("m") == "renewal" {
+			return PageCost
+		}
+	case "/clientarea.php":
+		switch query.Get("action") {
+		case "services":
+			return PageServices
+		case "productdetails":
+			return PageProductdetails
+		case "":
+			return PageClientarea
+		}
+	}
+	return ""
+}
+
+func (s *Server) serveLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeHTML(w, loginPage(""))
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.PostForm.Get("token") != csrfToken {
+		writeHTML(w, loginPage("Invalid CSRF Protection Token"))
+		return
+	}
+	if r.PostForm.Has("key") {
+		s.serveTwoFactor(w, r)
+		return
+	}
+	if r.PostForm.Get("username") != s.Username || r.PostForm.Get("password") != s.Password {
+		writeHTML(w, loginPage("Login Details Incorrect. Please try again."))
+		return
+	}
+	session := newSessionId()
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: session, Path: "/", HttpOnly: true})
+	if len(s.TotpSecret) != 0 {
+		s.mutex.Lock()
+		s.pending[session] = true
+		s.mutex.Unlock()
+		writeHTML(w, twoFactorPage(""))
+		return
+	}
+	s.loginSession(session)
+	writeHTML(w, s.fixture(PageClientarea))
+}
+
+// serveTwoFactor 校验两步验证码, 允许前后一个时间步长的误差
+func (s *Server) serveTwoFactor(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(sessionCookie)
+	s.mutex.Lock()
+	pending := err == nil && s.pending[cookie.Value]
+	s.mutex.Unlock()
+	if !pending {
+		writeHTML(w, loginPage("Session expired, please log in again."))
+		return
+	}
+	now := time.Now()
+	for _, t := range []time.Time{now, now.Add(-30 * time.Second), now.Add(30 * time.Second)} {
+		if code, err := totp.GenerateCode(s.TotpSecret, t); err == nil && code == r.PostForm.Get("key") {
+			s.mutex.Lock()
+			delete(s.pending, cookie.Value)
+			s.mutex.Unlock()
+			s.loginSession(cookie.Value)
+			writeHTML(w, s.fixture(PageClientarea))
+			return
+		}
+	}
+	writeHTML(w, twoFactorPage("Incorrect two-factor authentication code."))
+}
+
+// loginSession 标记会话已登录
+func (s *Server) loginSession(session string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sessions[session] = true
+	s.loginCount++
+}
+
+func (s *Server) loggedIn(r *http.Request) bool {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sessions[cookie.Value]
+}
+
+func newSessionId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// loginPage WHMCS 风格的登录表单, 包含 CSRF token hidden input
+func loginPage(errorMsg string) []byte {
+	alert := ""
+	if len(errorMsg) != 0 {
+		alert = fmt.Sprintf(`<div class="alert alert-danger">%s</div>`, errorMsg)
+	}
+	return []byte(fmt.Sprintf(`<!DOCTYPE html>
+<html><head><title>Login - VoLLcloud LLC</title></head>
+<body>
+<div class="pageError">%s</div>
+<form method="post" action="/index.php/login">
+    <input type="hidden" name="token" value="%s">
+    <input type="email" name="username">
+    <input type="password" name="password">
+    <button type="submit">Login</button>
+</form>
+</body></html>`, alert, csrfToken))
+}
+
+// twoFactorPage WHMCS 风格的两步验证表单
+func twoFactorPage(errorMsg string) []byte {
+	alert := ""
+	if len(errorMsg) != 0 {
+		alert = fmt.Sprintf(`<div class="alert alert-danger">%s</div>`, errorMsg)
+	}
+	return []byte(fmt.Sprintf(`<!DOCTYPE html>
+<html><head><title>Two-Factor Authentication - VoLLcloud LLC</title></head>
+<body>
+<div class="pageError">%s</div>
+<form method="post" action="/index.php/login">
+    <input type="hidden" name="token" value="%s">
+    <input type="text" name="key" autocomplete="off">
+    <button type="submit">Login</button>
+</form>
+</body></html>`, alert, csrfToken))
+}
+
+func writeHTML(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(body)
+}
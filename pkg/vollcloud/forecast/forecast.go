@@ -0,0 +1,143 @@
+package forecast
+
+import (
+	"sync"
+	"time"
+)
+
+// resetDropRatio 使用量低于上一次样本的该比例时, 视为流量已按周期重置
+const resetDropRatio = 0.5
+
+// minSamples 拟合使用速率所需的最少样本数
+const minSamples = 3
+
+// Sample 单次抓取时产品的流量使用量
+type Sample struct {
+	Time   time.Time
+	UsedGB float64
+}
+
+// History 每个产品最近 Window 内的流量样本, 仅保存在内存中, 进程重启后重新积累
+type History struct {
+	Window time.Duration
+
+	mutex   sync.Mutex
+	samples map[string][]Sample
+}
+
+func NewHistory(window time.Duration) *History {
+	return &History{
+		Window:  window,
+		samples: map[string][]Sample{},
+	}
+}
+
+// Add 记录产品的样本并丢弃超出 Window 的样本; 使用量骤降时清空历史从重置后重新计算, 返回是否检测到重置
+func (h *History) Add(productId string, sample Sample) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	samples := h.samples[productId]
+	reset := false
+	if n := len(samples); n > 0 {
+		last := samples[n-1]
+		if last.UsedGB > 0 && sample.UsedGB < last.UsedGB*resetDropRatio {
+			samples = nil
+			reset = true
+		}
+	}
+	samples = append(samples, sample)
+	cutoff := sample.Time.Add(-h.Window)
+	for len(samples) > 0 && samples[0].Time.Before(cutoff) {
+		samples = samples[1:]
+	}
+	h.samples[productId] = samples
+	return reset
+}
+
+// Retain 只保留 productIds 中产品的历史, 已删除的产品不再占用内存
+func (h *History) Retain(productIds []string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	keep := map[string]bool{}
+	for _, id := range productIds {
+		keep[id] = true
+	}
+	for id := range h.samples {
+		if !keep[id] {
+			delete(h.samples, id)
+		}
+	}
+}
+
+// Rate 对历史样本做最小二乘线性拟合, 返回使用速率 GB/second; 样本不足时返回 false
+func (h *History) Rate(productId string) (float64, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	samples := h.samples[productId]
+	if len(samples) < minSamples {
+		return 0, false
+	}
+	origin := samples[0].Time
+	var sumX, sumY, sumXX, sumXY float64
+	for _, s := range samples {
+		x := s.Time.Sub(origin).Seconds()
+		sumX += x
+		sumY += s.UsedGB
+		sumXX += x * x
+		sumXY += x * s.UsedGB
+	}
+	n := float64(len(samples))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	rate := (n*sumXY - sumX*sumY) / denominator
+	if rate < 0 {
+		rate = 0
+	}
+	return rate, true
+}
+
+// Forecast 按当前使用速率对周期结束时的预测
+type Forecast struct {
+	ProjectedGB float64 // 周期结束时预计使用量 GB
+	// ExhaustionTime 预计流量耗尽时间, 零值表示周期结束 (流量重置) 前不会耗尽或不限流量
+	ExhaustionTime time.Time
+}
+
+// Project 根据当前使用量及速率 (GB/second) 预测 periodEnd 时的使用量及流量耗尽时间, totalGB <= 0 表示不限流量
+func Project(usedGB, totalGB, rate float64, now, periodEnd time.Time) Forecast {
+	forecast := Forecast{ProjectedGB: usedGB}
+	if remaining := periodEnd.Sub(now).Seconds(); remaining > 0 {
+		forecast.ProjectedGB += rate * remaining
+	}
+	if totalGB <= 0 {
+		return forecast
+	}
+	if usedGB >= totalGB {
+		forecast.ExhaustionTime = now
+		return forecast
+	}
+	// 以 float 秒比较, 速率极小时换算为 time.Duration 会溢出; 周期结束前耗尽才输出, 此时时长不超过剩余时间
+	if remaining := periodEnd.Sub(now).Seconds(); rate > 0 && remaining > 0 {
+		if seconds := (totalGB - usedGB) / rate; seconds < remaining {
+			forecast.ExhaustionTime = now.Add(time.Duration(seconds * float64(time.Second)))
+		}
+	}
+	return forecast
+}
+
+// BurnRatio 流量使用百分比与流量周期已过去百分比之比, 大于 1 表示按当前进度会超出流量;
+// 周期刚开始 (已过去时间为 0) 时无法计算, 返回 false
+func BurnRatio(usagePercent float64, periodStart, periodEnd, now time.Time) (float64, bool) {
+	period := periodEnd.Sub(periodStart)
+	elapsed := now.Sub(periodStart)
+	if period <= 0 || elapsed <= 0 {
+		return 0, false
+	}
+	if elapsed > period {
+		elapsed = period
+	}
+	elapsedPercent := float64(elapsed) / float64(period) * 100
+	return usagePercent / elapsedPercent, true
+}
@@ -0,0 +1,162 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func TestHistoryRate(t *testing.T) {
+	h := NewHistory(24 * time.Hour)
+	if _, ok := h.Rate("1001"); ok {
+		t.Fatal("Rate without samples ok = true")
+	}
+	for i := 0; i < minSamples; i++ {
+		if _, ok := h.Rate("1001"); ok {
+			t.Fatalf("Rate with %d samples ok = true", i)
+		}
+		h.Add("1001", Sample{Time: t0.Add(time.Duration(i) * time.Hour), UsedGB: 10 + 3.6*float64(i)})
+	}
+	rate, ok := h.Rate("1001")
+	if !ok {
+		t.Fatal("Rate ok = false")
+	}
+	if want := 0.001; math.Abs(rate-want) > 1e-12 {
+		t.Errorf("Rate = %v GB/s, want %v", rate, want)
+	}
+	if _, ok := h.Rate("1002"); ok {
+		t.Error("Rate of unknown product ok = true")
+	}
+}
+
+func TestHistoryRateClampNegative(t *testing.T) {
+	h := NewHistory(24 * time.Hour)
+	for i, used := range []float64{100, 90, 80} {
+		h.Add("1001", Sample{Time: t0.Add(time.Duration(i) * time.Hour), UsedGB: used})
+	}
+	if rate, ok := h.Rate("1001"); !ok || rate != 0 {
+		t.Errorf("Rate = %v, %v, want 0, true", rate, ok)
+	}
+}
+
+func TestHistoryRateSameTime(t *testing.T) {
+	h := NewHistory(24 * time.Hour)
+	for _, used := range []float64{10, 11, 12} {
+		h.Add("1001", Sample{Time: t0, UsedGB: used})
+	}
+	if _, ok := h.Rate("1001"); ok {
+		t.Error("Rate with identical sample times ok = true")
+	}
+}
+
+func TestHistoryAddWindow(t *testing.T) {
+	h := NewHistory(2 * time.Hour)
+	for i := 0; i < 5; i++ {
+		h.Add("1001", Sample{Time: t0.Add(time.Duration(i) * time.Hour), UsedGB: float64(10 + i)})
+	}
+	// 2 小时窗口内保留 t0+2h, t0+3h, t0+4h
+	if n := len(h.samples["1001"]); n != 3 {
+		t.Errorf("samples = %d, want 3", n)
+	}
+	if first := h.samples["1001"][0].Time; !first.Equal(t0.Add(2 * time.Hour)) {
+		t.Errorf("first sample at %s, want %s", first, t0.Add(2*time.Hour))
+	}
+}
+
+func TestHistoryAddReset(t *testing.T) {
+	h := NewHistory(24 * time.Hour)
+	for i, used := range []float64{800, 810, 820} {
+		if reset := h.Add("1001", Sample{Time: t0.Add(time.Duration(i) * time.Hour), UsedGB: used}); reset {
+			t.Fatalf("Add(%v) reset = true", used)
+		}
+	}
+	// 小幅下降不视为重置
+	if reset := h.Add("1001", Sample{Time: t0.Add(3 * time.Hour), UsedGB: 500}); reset {
+		t.Fatal("Add(500) after 820 reset = true")
+	}
+	if reset := h.Add("1001", Sample{Time: t0.Add(4 * time.Hour), UsedGB: 1}); !reset {
+		t.Fatal("Add(1) after 500 reset = false")
+	}
+	if n := len(h.samples["1001"]); n != 1 {
+		t.Errorf("samples after reset = %d, want 1", n)
+	}
+	if _, ok := h.Rate("1001"); ok {
+		t.Error("Rate right after reset ok = true")
+	}
+	h.Add("1001", Sample{Time: t0.Add(5 * time.Hour), UsedGB: 4.6})
+	h.Add("1001", Sample{Time: t0.Add(6 * time.Hour), UsedGB: 8.2})
+	if rate, ok := h.Rate("1001"); !ok || math.Abs(rate-0.001) > 1e-12 {
+		t.Errorf("Rate after reset = %v, %v, want 0.001, true", rate, ok)
+	}
+	// 从 0 开始的历史不因 0 -> 0 判断为重置
+	if reset := h.Add("1002", Sample{Time: t0, UsedGB: 0}); reset {
+		t.Error("first Add reset = true")
+	}
+	if reset := h.Add("1002", Sample{Time: t0.Add(time.Hour), UsedGB: 0}); reset {
+		t.Error("Add(0) after 0 reset = true")
+	}
+}
+
+func TestHistoryRetain(t *testing.T) {
+	h := NewHistory(24 * time.Hour)
+	for _, id := range []string{"1001", "1002", "1003"} {
+		h.Add(id, Sample{Time: t0, UsedGB: 1})
+	}
+	h.Retain([]string{"1002"})
+	if len(h.samples) != 1 || h.samples["1002"] == nil {
+		t.Errorf("samples after Retain = %v, want only 1002", h.samples)
+	}
+}
+
+func TestProject(t *testing.T) {
+	periodEnd := t0.Add(10 * 24 * time.Hour)
+	tests := []struct {
+		name        string
+		used, total float64
+		rate        float64
+		now         time.Time
+		projected   float64
+		exhaustion  time.Time
+	}{
+		{"exhausted before reset", 100, 1000, 0.002, t0, 1828, t0.Add(450000 * time.Second)},
+		{"not exhausted before reset", 100, 1000, 0.0001, t0, 100 + 86.4, time.Time{}},
+		{"exhausted exactly at reset", 136, 1000, 0.001, t0, 1000, time.Time{}},
+		{"zero rate", 100, 1000, 0, t0, 100, time.Time{}},
+		{"tiny rate", 100, 1000, 1e-300, t0, 100, time.Time{}},
+		{"denormal rate", 100, 1000, math.SmallestNonzeroFloat64, t0, 100, time.Time{}},
+		{"unlimited", 100, 0, 0.001, t0, 964, time.Time{}},
+		{"already exhausted", 1000, 1000, 0, t0, 1000, t0},
+		{"over quota", 1200, 1000, 0.001, t0, 2064, t0},
+		{"after period end", 100, 1000, 0.001, periodEnd.Add(time.Hour), 100, time.Time{}},
+	}
+	for _, tt := range tests {
+		f := Project(tt.used, tt.total, tt.rate, tt.now, periodEnd)
+		if math.Abs(f.ProjectedGB-tt.projected) > 1e-6 {
+			t.Errorf("%s: ProjectedGB = %v, want %v", tt.name, f.ProjectedGB, tt.projected)
+		}
+		if !f.ExhaustionTime.Equal(tt.exhaustion) {
+			t.Errorf("%s: ExhaustionTime = %s, want %s", tt.name, f.ExhaustionTime, tt.exhaustion)
+		}
+		if !f.ExhaustionTime.IsZero() && (f.ExhaustionTime.Before(tt.now) || f.ExhaustionTime.After(periodEnd)) {
+			t.Errorf("%s: ExhaustionTime = %s, outside [%s, %s]", tt.name, f.ExhaustionTime, tt.now, periodEnd)
+		}
+	}
+}
+
+func TestBurnRatio(t *testing.T) {
+	periodEnd := t0.Add(10 * 24 * time.Hour)
+	if ratio, ok := BurnRatio(50, t0, periodEnd, t0.Add(5*24*time.Hour)); !ok || math.Abs(ratio-1) > 1e-9 {
+		t.Errorf("BurnRatio half way = %v, %v, want 1, true", ratio, ok)
+	}
+	if ratio, ok := BurnRatio(50, t0, periodEnd, t0.Add(24*time.Hour)); !ok || math.Abs(ratio-5) > 1e-9 {
+		t.Errorf("BurnRatio first day = %v, %v, want 5, true", ratio, ok)
+	}
+	if ratio, ok := BurnRatio(50, t0, periodEnd, periodEnd.Add(time.Hour)); !ok || math.Abs(ratio-0.5) > 1e-9 {
+		t.Errorf("BurnRatio after period end = %v, %v, want 0.5, true", ratio, ok)
+	}
+	if _, ok := BurnRatio(50, t0, periodEnd, t0); ok {
+		t.Error("BurnRatio at period start ok = true")
+	}
+}
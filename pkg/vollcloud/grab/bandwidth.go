@@ -0,0 +1,116 @@
+package grab
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"vollcloud-exporter/pkg/unit/conversion"
+)
+
+var (
+	// sizeRegexp 流量数值及单位, 如 "254.38 GB", "1,000GB", "512 MiB", "2T"
+	sizeRegexp = regexp.MustCompile(`(?i)(\d+(?:,\d{3})*(?:\.\d+)?)\s*([KMGTP]i?B|[KMGTP]|B)\b`)
+	// percentRegexp 使用百分比, 如 "25%"
+	percentRegexp = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*%`)
+	// unlimitedRegexp 不限流量套餐
+	unlimitedRegexp = regexp.MustCompile(`(?i)unlimited|unmetered|无限|不限|∞`)
+	// unknownRegexp 面板无法获取流量时的占位内容
+	unknownRegexp = regexp.MustCompile(`(?i)^\s*(?:n/?a|unknown|未知|暂无|-+)?\s*$`)
+)
+
+// Bandwidth 流量解析结果, 单位 GB; Unlimited 时 TotalGB/FreeGB/Usage 为 0
+type Bandwidth struct {
+	UsedGB    float64
+	TotalGB   float64
+	FreeGB    float64
+	Usage     float64 // 使用百分比
+	Unlimited bool
+}
+
+// ParseBandwidth 解析流量描述, 不会 panic, 无法解析时返回 *ErrParse.
+// 支持格式:
+//
+//	"254.38 GB of 1000 GB Used / 745.62 GB Free 25%"
+//	"254.38 GB of Unlimited Used"
+//	中文界面按相同顺序出现的 已用 / 总量 / 剩余, 如 "254.38 GB 共 1000 GB 已用 / 745.62 GB 剩余 25%"
+func ParseBandwidth(s string) (Bandwidth, error) {
+	bandwidth := Bandwidth{}
+	text := strings.Join(strings.Fields(s), " ")
+	if unknownRegexp.MatchString(text) {
+		return bandwidth, &ErrParse{Field: "bandwidth", Raw: s}
+	}
+	var sizes []float64
+	for _, match := range sizeRegexp.FindAllStringSubmatch(text, -1) {
+		gb, err := parseSizeGB(match[1], match[2])
+		if err != nil {
+			return bandwidth, &ErrParse{Field: "bandwidth", Raw: s, Err: err}
+		}
+		sizes = append(sizes, gb)
+	}
+
+	if unlimitedRegexp.MatchString(text) {
+		if len(sizes) == 0 {
+			return bandwidth, &ErrParse{Field: "bandwidth_used", Raw: s}
+		}
+		bandwidth.UsedGB = sizes[0]
+		bandwidth.Unlimited = true
+		return bandwidth, nil
+	}
+	if len(sizes) < 2 {
+		return bandwidth, &ErrParse{Field: "bandwidth_total", Raw: s}
+	}
+	bandwidth.UsedGB = sizes[0]
+	bandwidth.TotalGB = sizes[1]
+	if len(sizes) >= 3 {
+		bandwidth.FreeGB = sizes[2]
+	} else {
+		bandwidth.FreeGB = bandwidth.TotalGB - bandwidth.UsedGB
+	}
+	if match := percentRegexp.FindStringSubmatch(text); match != nil {
+		usage, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return bandwidth, &ErrParse{Field: "bandwidth_usage", Raw: s, Err: err}
+		}
+		bandwidth.Usage = usage
+	} else if bandwidth.TotalGB > 0 {
+		bandwidth.Usage = bandwidth.UsedGB / bandwidth.TotalGB * 100
+	}
+	return bandwidth, nil
+}
+
+// ParseSizeBytes 解析内存/硬盘容量描述为字节, 如 "2048 MB", "10 GB"; 无法解析时返回 *ErrParse{Field: field}
+func ParseSizeBytes(field, s string) (float64, error) {
+	match := sizeRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return 0, &ErrParse{Field: field, Raw: s}
+	}
+	gb, err := parseSizeGB(match[1], match[2])
+	if err != nil {
+		return 0, &ErrParse{Field: field, Raw: s, Err: err}
+	}
+	return conversion.GBtoB(gb), nil
+}
+
+// parseSizeGB 数值按单位换算为 GB, 单位不区分大小写, KiB/KB/K 均按 1024 进制
+func parseSizeGB(number, unit string) (float64, error) {
+	n, err := strconv.ParseFloat(strings.ReplaceAll(number, ",", ""), 64)
+	if err != nil {
+		return 0, err
+	}
+	switch strings.ToUpper(unit[:1]) {
+	case "B":
+		return conversion.BtoGB(n), nil
+	case "K":
+		return conversion.KBtoGB(n), nil
+	case "M":
+		return conversion.MBtoGB(n), nil
+	case "G":
+		return n, nil
+	case "T":
+		return conversion.TBtoGB(n), nil
+	case "P":
+		return conversion.PBtoGB(n), nil
+	}
+	return 0, &ErrParse{Field: "size_unit", Raw: number + " " + unit}
+}
@@ -0,0 +1,113 @@
+package grab
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Bandwidth
+	}{
+		{"fixture", "254.38 GB of 1000 GB Used / 745.62 GB Free\n\n\n                                25%",
+			Bandwidth{UsedGB: 254.38, TotalGB: 1000, FreeGB: 745.62, Usage: 25}},
+		{"bold total", "321.74 GB of <strong>1000 GB</strong> Used / 678.26 GB Free 32%",
+			Bandwidth{UsedGB: 321.74, TotalGB: 1000, FreeGB: 678.26, Usage: 32}},
+		{"no free no percent", "250 GB of 1000 GB Used",
+			Bandwidth{UsedGB: 250, TotalGB: 1000, FreeGB: 750, Usage: 25}},
+		{"thousands separator", "1,024 GB of 2,048 GB Used / 1,024 GB Free 50%",
+			Bandwidth{UsedGB: 1024, TotalGB: 2048, FreeGB: 1024, Usage: 50}},
+		{"bytes", "1073741824 B of 2147483648 B Used",
+			Bandwidth{UsedGB: 1, TotalGB: 2, FreeGB: 1, Usage: 50}},
+		{"KB", "1048576 KB of 2097152 KB Used",
+			Bandwidth{UsedGB: 1, TotalGB: 2, FreeGB: 1, Usage: 50}},
+		{"MB", "512 MB of 1024 MB Used / 512 MB Free 50%",
+			Bandwidth{UsedGB: 0.5, TotalGB: 1, FreeGB: 0.5, Usage: 50}},
+		{"MiB lowercase", "512 mib of 1 gib used",
+			Bandwidth{UsedGB: 0.5, TotalGB: 1, FreeGB: 0.5, Usage: 50}},
+		{"TB", "0.5 TB of 2 TB Used / 1.5 TB Free 25%",
+			Bandwidth{UsedGB: 512, TotalGB: 2048, FreeGB: 1536, Usage: 25}},
+		{"PB", "0.25 PB of 1 PB Used",
+			Bandwidth{UsedGB: 262144, TotalGB: 1048576, FreeGB: 786432, Usage: 25}},
+		{"unlimited", "254.38 GB of Unlimited Used",
+			Bandwidth{UsedGB: 254.38, Unlimited: true}},
+		{"unmetered", "12 GB Used / Unmetered",
+			Bandwidth{UsedGB: 12, Unlimited: true}},
+		{"chinese", "254.38 GB 共 1000 GB 已用 / 745.62 GB 剩余 25%",
+			Bandwidth{UsedGB: 254.38, TotalGB: 1000, FreeGB: 745.62, Usage: 25}},
+		{"chinese unlimited", "已用 254.38 GB / 不限流量",
+			Bandwidth{UsedGB: 254.38, Unlimited: true}},
+	}
+	for _, tt := range tests {
+		got, err := ParseBandwidth(tt.in)
+		if err != nil {
+			t.Errorf("%s: ParseBandwidth(%q) error: %v", tt.name, tt.in, err)
+			continue
+		}
+		if !bandwidthEqual(got, tt.want) {
+			t.Errorf("%s: ParseBandwidth(%q) = %+v, want %+v", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseBandwidthError(t *testing.T) {
+	tests := []struct {
+		in    string
+		field string
+	}{
+		{"", "bandwidth"},
+		{"N/A", "bandwidth"},
+		{"n/a", "bandwidth"},
+		{"Unknown", "bandwidth"},
+		{"未知", "bandwidth"},
+		{"--", "bandwidth"},
+		{"Unlimited", "bandwidth_used"},
+		{"254.38 GB Used", "bandwidth_total"},
+		{"loading...", "bandwidth_total"},
+	}
+	for _, tt := range tests {
+		_, err := ParseBandwidth(tt.in)
+		var parseErr *ErrParse
+		if !errors.As(err, &parseErr) {
+			t.Errorf("ParseBandwidth(%q) error = %v, want *ErrParse", tt.in, err)
+			continue
+		}
+		if parseErr.Field != tt.field {
+			t.Errorf("ParseBandwidth(%q) field = %s, want %s", tt.in, parseErr.Field, tt.field)
+		}
+	}
+}
+
+// FuzzParseBandwidth 任意输入都不能 panic, 成功时结果不能为 NaN 或负数
+func FuzzParseBandwidth(f *testing.F) {
+	for _, seed := range []string{
+		"254.38 GB of 1000 GB Used / 745.62 GB Free 25%",
+		"254.38 GB of Unlimited Used",
+		"254.38 GB 共 1000 GB 已用 / 745.62 GB 剩余 25%",
+		"N/A",
+		"1,000,000.5 PiB of 1e309 TB 999999999999999999999%",
+		"",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		bandwidth, err := ParseBandwidth(s)
+		if err != nil {
+			return
+		}
+		for _, v := range []float64{bandwidth.UsedGB, bandwidth.TotalGB, bandwidth.Usage} {
+			if math.IsNaN(v) || v < 0 {
+				t.Errorf("ParseBandwidth(%q) = %+v, invalid value", s, bandwidth)
+			}
+		}
+	})
+}
+
+func bandwidthEqual(a, b Bandwidth) bool {
+	near := func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	return near(a.UsedGB, b.UsedGB) && near(a.TotalGB, b.TotalGB) && near(a.FreeGB, b.FreeGB) &&
+		near(a.Usage, b.Usage) && a.Unlimited == b.Unlimited
+}
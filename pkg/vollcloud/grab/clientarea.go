@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/spf13/viper"
@@ -12,38 +11,37 @@ import (
 
 type Clientarea struct {
 	HttpClient *http.Client
+	Account    string
 	Doc        *goquery.Document
 	IdUrls     []string
 }
 
-func NewClientarea(httpClient http.Client) *Clientarea {
+func NewClientarea(httpClient http.Client, account string) *Clientarea {
 	return &Clientarea{
 		HttpClient: &httpClient,
+		Account:    account,
 	}
 }
 
-func (c *Clientarea) Get() {
+func (c *Clientarea) Get() error {
 	url := viper.GetString("vollcloud.clientarea.url")
-	resp, err := c.HttpClient.Get(url)
+	doc, err := getDocument(c.HttpClient, c.Account, "clientarea", url)
 	if err != nil {
-		log.Println("Failed clientarea Get error: ", err.Error())
-		return
-	}
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		log.Println("Failed clientarea goquery error: ", err.Error())
-		return
+		return err
 	}
 	c.Doc = doc
+	return nil
 }
 
+// IfUserLogin 返回当前登录用户名, 会话过期时返回的错误包含 ErrNotLoggedIn
 func (c *Clientarea) IfUserLogin() (string, error) {
-	headerUsername := strings.TrimSpace(c.Doc.Find(".nav-item.dropdown.account .nav-link.dropdown-toggle").Text())
-
-	if len(headerUsername) == 0 {
-		pageTitleBox := c.Doc.Find("title").Text() + c.Doc.Find("div.pageError").Text()
-		log.Println("Failed user Login, msg: ", strings.Fields(pageTitleBox))
-		return "", fmt.Errorf("Failed Login %s ", strings.Fields(pageTitleBox))
+	if c.Doc == nil {
+		return "", fmt.Errorf("Failed Login clientarea page not fetched")
+	}
+	headerUsername, err := getUsername("clientarea", c.Doc)
+	if err != nil {
+		log.Println(err.Error())
+		return "", err
 	}
 	log.Println("Info The current login user is: ", headerUsername)
 	return headerUsername, nil
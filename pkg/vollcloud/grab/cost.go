@@ -3,29 +3,47 @@ package grab
 import (
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/spf13/viper"
 
+	"vollcloud-exporter/pkg/unit/conversion"
 	"vollcloud-exporter/pkg/unit/date"
 	"vollcloud-exporter/pkg/unit/url_parse"
+	"vollcloud-exporter/pkg/vollcloud/metrics"
 )
 
 type Cost struct {
 	HttpClient *http.Client
+	Account    string
 	Doc        *goquery.Document
-	CostInfos  []CostInfo
+	Entries    []RenewalEntry
 }
 
-func NewCost(httpClient http.Client) *Cost {
+func NewCost(httpClient http.Client, account string) *Cost {
 	return &Cost{
 		HttpClient: &httpClient,
+		Account:    account,
 	}
 }
 
+// RenewalEntry 续费页面表格中的一行
+type RenewalEntry struct {
+	ProductId    string
+	ResourceName string // 资源名, 如 "新产品-【HK-Group 13】"
+	Description  string // 产品描述, 一般为主机名
+	IPv4         string
+	DueDate      string // 到期时间 2006-01-02, 解析失败时为空
+	Cycle        date.BillingCycle
+	PriceUSD     float64
+}
+
+// CostInfo 单个付费周期的成本
 type CostInfo struct {
 	DateStart      string
 	DateEnd        string
@@ -34,130 +52,152 @@ type CostInfo struct {
 	ProductId      string
 }
 
+// costSelector 续费页面表格
+const costSelector = "div.renewal-bd-table .table"
+
+// costRequiredColumns 续费表格中必须存在的列, 缺少任意一列时无法计算成本
+var costRequiredColumns = []Column{ColumnDueDate, ColumnCycle, ColumnPrice, ColumnActions}
+
 // GetCost 获取成本页面
 func (c *Cost) GetCost() error {
 	costUrl := viper.GetString("vollcloud.cost.url")
-	resp, err := c.HttpClient.Get(costUrl)
+	doc, err := getDocument(c.HttpClient, c.Account, "cost", costUrl)
 	if err != nil {
-		msg := fmt.Sprintf("Failed Cost Get error: %s %s", costUrl, err.Error())
-		log.Println(msg)
-		return fmt.Errorf(msg)
+		return err
 	}
-	if resp.StatusCode != 200 {
-		msg := fmt.Sprintf("Failed Cost Get StatusCode not is 200, it is %v", resp.StatusCode)
-		log.Println(msg)
-		return fmt.Errorf(msg)
-	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		msg := fmt.Sprintf("Failed Cost goquery error: %s", err.Error())
-		log.Println(msg)
-		return fmt.Errorf(msg)
+	if _, err := getUsername("cost", doc); err != nil {
+		log.Println(err.Error())
+		return err
 	}
 	c.Doc = doc
 	return nil
 }
 
-// GetCostInfos 解析 html 中的成本页面
-func (c *Cost) GetCostInfos() {
-	c.Doc.Find("div.renewal-bd-table .table tbody tr").Each(func(i int, s *goquery.Selection) {
-		costInfo := CostInfo{}
-		s.Find("td").Each(func(itd int, std *goquery.Selection) {
-			if itd == 4 {
-				dateEnd := strings.TrimSpace(std.Text())
-				costInfo.DateEnd = dateEnd
-			}
-			if itd == 5 {
-				cycle := strings.TrimSpace(std.Text())
-				costInfo.CostCycle = cycle
-			}
-			if itd == 6 {
-				blendedCostUSD := strings.TrimSpace(std.Text())
-				usd, err := strconv.ParseFloat(strings.ReplaceAll(strings.ReplaceAll(blendedCostUSD, "$", ""), " USD", ""), 64)
-				if err != nil {
-					log.Println("Failed GetCostInfos blendedCostUSD Recurring Amount", err.Error())
-				}
-				costInfo.BlendedCostUSD = usd
-			}
-			if itd == 7 {
-				idUrl, IsExist := std.Find("a").Attr("href")
-				if IsExist {
-					sid, err := url_parse.GetParameId(idUrl, "sid")
-					if err != nil {
-						log.Println("Failed GetCostInfos GetParameId", err.Error())
-					}
-					costInfo.ProductId = sid
-				}
-			}
-			dateStart, err := getDateStart(costInfo.DateEnd, costInfo.CostCycle)
-			if err != nil {
-				log.Println("Failed GetCostInfos getDateStart", err.Error())
+// GetRenewalEntries 解析续费页面表格, 每行一个 RenewalEntry; 按表头定位各列, 缺少必需列时返回 ErrLayoutChanged
+func (c *Cost) GetRenewalEntries() error {
+	table := c.Doc.Find(costSelector)
+	columns := headerColumns(table)
+	for _, column := range costRequiredColumns {
+		if _, ok := columns[column]; !ok {
+			metrics.ParseError(c.Account, "cost_column")
+			err := &ErrLayoutChanged{Page: "cost", Selector: fmt.Sprintf("%s thead th %s", costSelector, column)}
+			log.Println("Failed GetRenewalEntries ", err.Error())
+			return err
+		}
+	}
+	c.Entries = nil
+	table.Find("tbody tr").Each(func(i int, s *goquery.Selection) {
+		tds := s.Find("td")
+		cell := func(column Column) *goquery.Selection {
+			index, ok := columns[column]
+			if !ok {
+				return &goquery.Selection{}
 			}
-			if len(dateStart) == 0 {
-				dateStart = date.GetNowDay()
+			return tds.Eq(index)
+		}
+		// 没有续费链接的行 (如 "暂无记录" 空状态提示) 不是产品, 跳过避免输出 product_id="" 的成本
+		idUrl, _ := cell(ColumnActions).Find("a").Attr("href")
+		sid, err := url_parse.GetParameId(idUrl, "sid")
+		if err != nil || len(sid) == 0 {
+			// 空状态提示为合并单元格的单列, 其余情况说明续费链接结构变化
+			if tds.Length() > 1 {
+				metrics.ParseError(c.Account, "cost_product_id")
 			}
-			costInfo.DateStart = dateStart
-
-			c.CostInfos = append(c.CostInfos, costInfo)
-		})
-		c.CostInfos = append(c.CostInfos, costInfo)
-		c.CostInfos = getCycleCost(c.CostInfos, costInfo)
+			log.Println("Warn GetRenewalEntries skip row without product id: ", strings.Join(strings.Fields(s.Text()), " "))
+			return
+		}
+		entry := RenewalEntry{
+			ProductId:    sid,
+			ResourceName: strings.TrimSpace(cell(ColumnResourceName).Text()),
+			Description:  strings.TrimSpace(cell(ColumnDescription).Text()),
+			IPv4:         strings.TrimSpace(cell(ColumnIPv4).Text()),
+			Cycle:        ParseCycle(cell(ColumnCycle).Text()),
+		}
+		dueDate, err := ParseDate(cell(ColumnDueDate).Text())
+		if err != nil {
+			metrics.ParseError(c.Account, "cost_date_end")
+			log.Println("Failed GetRenewalEntries ParseDate", err.Error())
+		}
+		entry.DueDate = dueDate
+		if entry.Cycle == date.CycleUnknown {
+			metrics.ParseError(c.Account, "cost_cycle")
+			log.Println("Failed GetRenewalEntries ParseCycle", strings.TrimSpace(cell(ColumnCycle).Text()))
+		}
+		price := strings.TrimSpace(cell(ColumnPrice).Text())
+		usd, err := strconv.ParseFloat(strings.ReplaceAll(strings.ReplaceAll(price, "$", ""), " USD", ""), 64)
+		if err != nil {
+			metrics.ParseError(c.Account, "cost_amount")
+			log.Println("Failed GetRenewalEntries price Recurring Amount", err.Error())
+		}
+		entry.PriceUSD = usd
+		c.Entries = append(c.Entries, entry)
 	})
-	log.Println("Info GetCostInfos success: ", len(c.CostInfos))
-}
-
-// getCycleCost 获取更多周期账单的成本, 以当前时间为维度, 当期账单, 及以续费后的账单
-func getCycleCost(costInfos []CostInfo, costInfo CostInfo) []CostInfo {
-	ok, err := date.IfDateBigNow(costInfo.DateStart)
-	if err != nil {
-		return costInfos
-	}
-	if ok {
-		return costInfos
-	}
-
-	dateEnd := costInfo.DateStart
-	dateStart, _ := getDateStart(dateEnd, costInfo.CostCycle)
-	newCostInfo := CostInfo{
-		DateStart:      dateStart,
-		DateEnd:        dateEnd,
-		BlendedCostUSD: costInfo.BlendedCostUSD,
-		CostCycle:      costInfo.CostCycle,
-		ProductId:      costInfo.ProductId,
-	}
-	costInfos = append(costInfos, newCostInfo)
-	return getCycleCost(costInfos, newCostInfo)
+	log.Println("Info GetRenewalEntries success: ", len(c.Entries))
+	return nil
 }
 
-// getDateStart 计算付费周期的起始时间
-func getDateStart(dateEnd string, cycle string) (string, error) {
-	if cycle == "每年" || cycle == "year" {
-		return date.GetDateBeforeYear(dateEnd, -1)
-	}
-	if cycle == "月" || cycle == "month" {
-		return date.GetDateBeforeMonth(dateEnd, -1)
+// ExpandCostInfos 将续费条目展开为付费周期: 以到期时间为终点向前推算, 直到覆盖 now 所在的周期.
+// 已过期的条目只返回最后一个周期, 到期时间无法解析或非周期性付费的条目被忽略
+func ExpandCostInfos(entries []RenewalEntry, now time.Time) []CostInfo {
+	var costInfos []CostInfo
+	today := now.Format("2006-01-02")
+	for _, entry := range entries {
+		if len(entry.DueDate) == 0 {
+			continue
+		}
+		if !entry.Cycle.Recurring() {
+			log.Println("Warn ExpandCostInfos skip not recurring cycle: ", entry.ProductId, entry.Cycle)
+			continue
+		}
+		due, err := time.Parse("2006-01-02", entry.DueDate)
+		if err != nil {
+			log.Println("Failed ExpandCostInfos DueDate", err.Error())
+			continue
+		}
+		// 每个周期边界都从到期时间推算, 避免月末日期截断后逐期累积偏移: 03-31 -> 02-28 -> 01-31
+		for k := 1; ; k++ {
+			dateStart := entry.Cycle.AddTo(due, -k).Format("2006-01-02")
+			costInfos = append(costInfos, CostInfo{
+				DateStart:      dateStart,
+				DateEnd:        entry.Cycle.AddTo(due, 1-k).Format("2006-01-02"),
+				BlendedCostUSD: entry.PriceUSD,
+				CostCycle:      string(entry.Cycle),
+				ProductId:      entry.ProductId,
+			})
+			// 起始时间不晚于 now 时, 已覆盖当期账单
+			if dateStart <= today {
+				break
+			}
+		}
 	}
-	return date.GetNowDay(), nil
+	return costInfos
 }
 
-// SplitCostCycle 将成本拆分: 年/月/日 (日只返回最近7天的)
+// SplitCostCycle 将成本拆分: 整个周期/月/日 (日只返回最近7天且在周期内的).
+// 按周期实际天数以整数美分平摊, 各月份 (或各日) 之和恰好等于账单金额
 func SplitCostCycle(cost CostInfo) []CostInfo {
 	var costs []CostInfo
-	cycle, err := date.GetDateSubPeriodUnit(cost.DateStart, cost.DateEnd)
-	if err != nil {
-		log.Println("Failed SplitCostCycle error", cycle, err.Error())
+	cycle := date.BillingCycle(cost.CostCycle)
+	if !cycle.Recurring() {
+		var err error
+		cycle, err = date.GetDateSubPeriodUnit(cost.DateStart, cost.DateEnd)
+		if err != nil {
+			log.Println("Failed SplitCostCycle error", cost.CostCycle, err.Error())
+			return costs
+		}
+	}
+	periodDays, err := periodOffset(cost.DateStart, cost.DateEnd)
+	if err != nil || periodDays <= 0 {
+		log.Println("Failed SplitCostCycle period days", cost.DateStart, cost.DateEnd)
 		return costs
 	}
 	costs = append(costs, CostInfo{
 		DateStart:      cost.DateStart,
 		DateEnd:        cost.DateEnd,
 		BlendedCostUSD: cost.BlendedCostUSD,
-		CostCycle:      cycle,
+		CostCycle:      string(cycle),
 	})
-	if cycle == "year" || cycle == "每年" {
-		newCycle := "month"
-		costDay := cost.BlendedCostUSD / 365
+	if cycle.Months() > 1 {
 		dateRangeMonth, err := date.GetDateRangeYearToMonth(cost.DateStart, cost.DateEnd)
 		if err != nil {
 			log.Println("Warn GetDateRangeYearToMonth", dateRangeMonth, err.Error())
@@ -167,47 +207,96 @@ func SplitCostCycle(cost CostInfo) []CostInfo {
 			if i+1 >= len(dateRangeMonth) {
 				continue
 			}
-			day, err := date.GetDateSubPeriodDays(m, dateRangeMonth[i+1])
+			usd, err := AccruedUSD(cost, m, dateRangeMonth[i+1])
 			if err != nil {
-				log.Println("Warn GetDateSubPeriodDays", err.Error())
 				continue
 			}
 			costs = append(costs, CostInfo{
 				DateStart:      m,
 				DateEnd:        dateRangeMonth[i+1],
-				BlendedCostUSD: day * costDay,
-				CostCycle:      newCycle,
-			})
-		}
-		newCycle = "day"
-		dateRangeDays := date.GetDateRangeToDay(date.GetBeforeDay(-7), date.GetNowDay())
-		for i, d := range dateRangeDays {
-			if i+1 >= len(dateRangeDays) {
-				continue
-			}
-			costs = append(costs, CostInfo{
-				DateStart:      d,
-				DateEnd:        dateRangeDays[i+1],
-				BlendedCostUSD: costDay,
-				CostCycle:      newCycle,
+				BlendedCostUSD: usd,
+				CostCycle:      string(date.CycleMonthly),
 			})
 		}
 	}
-	if cycle == "month" || cycle == "每月" {
-		newCycle := "day"
-		costDay := cost.BlendedCostUSD / 30
-		dateRangeDays := date.GetDateRangeToDay(date.GetBeforeDay(-7), date.GetNowDay())
-		for i, d := range dateRangeDays {
-			if i+1 >= len(dateRangeDays) {
-				continue
-			}
-			costs = append(costs, CostInfo{
-				DateStart:      d,
-				DateEnd:        dateRangeDays[i+1],
-				BlendedCostUSD: costDay,
-				CostCycle:      newCycle,
-			})
+	dateRangeDays := date.GetDateRangeToDay(date.GetBeforeDay(-7), date.GetNowDay())
+	for i, d := range dateRangeDays {
+		if i+1 >= len(dateRangeDays) || d < cost.DateStart || dateRangeDays[i+1] > cost.DateEnd {
+			continue
+		}
+		usd, err := AccruedUSD(cost, d, dateRangeDays[i+1])
+		if err != nil {
+			continue
 		}
+		costs = append(costs, CostInfo{
+			DateStart:      d,
+			DateEnd:        dateRangeDays[i+1],
+			BlendedCostUSD: usd,
+			CostCycle:      "day",
+		})
 	}
 	return costs
 }
+
+// AccruedUSD 付费周期在区间 [dateStart, dateEnd) 内分摊的金额, 区间超出周期的部分不计.
+// 以整数美分计算, 相邻区间之和等于合并区间的金额
+func AccruedUSD(cost CostInfo, dateStart, dateEnd string) (float64, error) {
+	periodDays, err := periodOffset(cost.DateStart, cost.DateEnd)
+	if err != nil {
+		return 0, err
+	}
+	start, err := periodOffset(cost.DateStart, dateStart)
+	if err != nil {
+		return 0, err
+	}
+	end, err := periodOffset(cost.DateStart, dateEnd)
+	if err != nil {
+		return 0, err
+	}
+	if end <= start {
+		return 0, nil
+	}
+	totalCents := conversion.USDtoCents(cost.BlendedCostUSD)
+	cents := conversion.AccruedCents(totalCents, periodDays, end) - conversion.AccruedCents(totalCents, periodDays, start)
+	return conversion.CentsToUSD(cents), nil
+}
+
+// DailyRateUSD 付费周期按实际天数计算的每日成本
+func DailyRateUSD(cost CostInfo) (float64, error) {
+	periodDays, err := periodOffset(cost.DateStart, cost.DateEnd)
+	if err != nil {
+		return 0, err
+	}
+	if periodDays <= 0 {
+		return 0, fmt.Errorf("invalid billing period %s - %s", cost.DateStart, cost.DateEnd)
+	}
+	return cost.BlendedCostUSD / float64(periodDays), nil
+}
+
+// CurrentCostInfo 产品在 now 所在的付费周期; 没有覆盖 now 的周期时返回结束时间最晚的周期
+func CurrentCostInfo(costInfos []CostInfo, productId string, now time.Time) (CostInfo, bool) {
+	today := now.Format("2006-01-02")
+	var current CostInfo
+	found := false
+	for _, cost := range costInfos {
+		if cost.ProductId != productId {
+			continue
+		}
+		if cost.DateStart <= today && today < cost.DateEnd {
+			return cost, true
+		}
+		if !found || cost.DateEnd > current.DateEnd {
+			current, found = cost, true
+		}
+	}
+	return current, found
+}
+
+// periodOffset dateStart 到 dateEnd 的整天数
+func periodOffset(dateStart, dateEnd string) (int, error) {
+	days, err := date.GetDateSubPeriodDays(dateStart, dateEnd)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(days)), nil
+}
@@ -0,0 +1,195 @@
+package grab
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"vollcloud-exporter/pkg/unit/conversion"
+	"vollcloud-exporter/pkg/unit/date"
+)
+
+func TestExpandCostInfos(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		entry RenewalEntry
+		want  [][2]string
+	}{
+		{"current monthly", RenewalEntry{DueDate: "2022-06-20", Cycle: date.CycleMonthly},
+			[][2]string{{"2022-05-20", "2022-06-20"}}},
+		{"starts today", RenewalEntry{DueDate: "2022-07-01", Cycle: date.CycleMonthly},
+			[][2]string{{"2022-06-01", "2022-07-01"}}},
+		{"expired", RenewalEntry{DueDate: "2022-01-10", Cycle: date.CycleAnnually},
+			[][2]string{{"2021-01-10", "2022-01-10"}}},
+		{"expired monthly", RenewalEntry{DueDate: "2021-03-31", Cycle: date.CycleMonthly},
+			[][2]string{{"2021-02-28", "2021-03-31"}}},
+		{"walk back monthly", RenewalEntry{DueDate: "2022-09-15", Cycle: date.CycleMonthly},
+			[][2]string{{"2022-08-15", "2022-09-15"}, {"2022-07-15", "2022-08-15"}, {"2022-06-15", "2022-07-15"}, {"2022-05-15", "2022-06-15"}}},
+		{"walk back quarterly", RenewalEntry{DueDate: "2023-01-01", Cycle: date.CycleQuarterly},
+			[][2]string{{"2022-10-01", "2023-01-01"}, {"2022-07-01", "2022-10-01"}, {"2022-04-01", "2022-07-01"}}},
+		{"walk back triennial", RenewalEntry{DueDate: "2027-03-01", Cycle: date.CycleTriennially},
+			[][2]string{{"2024-03-01", "2027-03-01"}, {"2021-03-01", "2024-03-01"}}},
+		{"one time", RenewalEntry{DueDate: "2022-09-15", Cycle: date.CycleOneTime}, nil},
+		{"unknown cycle", RenewalEntry{DueDate: "2022-09-15", Cycle: date.CycleUnknown}, nil},
+		{"empty due date", RenewalEntry{Cycle: date.CycleMonthly}, nil},
+		{"invalid due date", RenewalEntry{DueDate: "15/09/2022", Cycle: date.CycleMonthly}, nil},
+	}
+	for _, tt := range tests {
+		tt.entry.ProductId = "1001"
+		tt.entry.PriceUSD = 5.5
+		got := ExpandCostInfos([]RenewalEntry{tt.entry}, now)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: ExpandCostInfos = %+v, want %d periods", tt.name, got, len(tt.want))
+			continue
+		}
+		for i, period := range tt.want {
+			if got[i].DateStart != period[0] || got[i].DateEnd != period[1] {
+				t.Errorf("%s: period %d = %s - %s, want %s - %s", tt.name, i, got[i].DateStart, got[i].DateEnd, period[0], period[1])
+			}
+			if got[i].ProductId != "1001" || got[i].BlendedCostUSD != 5.5 || got[i].CostCycle != string(tt.entry.Cycle) {
+				t.Errorf("%s: period %d = %+v, want product 1001 price 5.5 cycle %s", tt.name, i, got[i], tt.entry.Cycle)
+			}
+		}
+	}
+}
+
+func TestExpandCostInfosMultipleEntries(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	entries := []RenewalEntry{
+		{ProductId: "1001", DueDate: "2022-06-20", Cycle: date.CycleMonthly},
+		{ProductId: "1002", Cycle: date.CycleMonthly},
+		{ProductId: "1003", DueDate: "2022-08-01", Cycle: date.CycleMonthly},
+	}
+	var ids []string
+	for _, costInfo := range ExpandCostInfos(entries, now) {
+		ids = append(ids, costInfo.ProductId)
+	}
+	if want := []string{"1001", "1003", "1003"}; strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("ExpandCostInfos products = %v, want %v", ids, want)
+	}
+}
+
+func TestExpandCostInfosMonthEnd(t *testing.T) {
+	entries := []RenewalEntry{{ProductId: "1001", DueDate: "2023-03-31", Cycle: date.CycleMonthly, PriceUSD: 5}}
+	now := time.Date(2022, 12, 15, 0, 0, 0, 0, time.UTC)
+	want := [][2]string{
+		{"2023-02-28", "2023-03-31"},
+		{"2023-01-31", "2023-02-28"},
+		{"2022-12-31", "2023-01-31"},
+		{"2022-11-30", "2022-12-31"},
+	}
+	got := ExpandCostInfos(entries, now)
+	if len(got) != len(want) {
+		t.Fatalf("ExpandCostInfos = %+v, want %d periods", got, len(want))
+	}
+	for i, period := range want {
+		if got[i].DateStart != period[0] || got[i].DateEnd != period[1] {
+			t.Errorf("period %d = %s - %s, want %s - %s", i, got[i].DateStart, got[i].DateEnd, period[0], period[1])
+		}
+	}
+}
+
+// TestSplitCostCycleSum 随机账单金额、周期及起始日期, 按月拆分的各段首尾相接、不跨月, 且美分之和恰好等于账单金额
+func TestSplitCostCycleSum(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2000; i++ {
+		cycle := date.RecurringCycles[r.Intn(len(date.RecurringCycles))]
+		start := base.AddDate(0, 0, r.Intn(3000))
+		cost := CostInfo{
+			DateStart:      start.Format("2006-01-02"),
+			DateEnd:        cycle.AddTo(start, 1).Format("2006-01-02"),
+			BlendedCostUSD: conversion.CentsToUSD(r.Int63n(1000000)),
+			CostCycle:      string(cycle),
+		}
+		costs := SplitCostCycle(cost)
+		if len(costs) == 0 || costs[0] != cost {
+			t.Fatalf("SplitCostCycle(%+v) first = %+v, want the whole period", cost, costs)
+		}
+		for _, piece := range costs {
+			if piece.DateStart >= piece.DateEnd {
+				t.Fatalf("SplitCostCycle(%+v) piece %+v is empty", cost, piece)
+			}
+		}
+		if cycle.Months() == 1 {
+			continue
+		}
+		var sum int64
+		dateEnd := cost.DateStart
+		for _, month := range costs[1:] {
+			if month.CostCycle != string(date.CycleMonthly) {
+				continue
+			}
+			if month.DateStart != dateEnd || month.DateStart[:7] != month.DateEnd[:7] && month.DateEnd[8:] != "01" {
+				t.Fatalf("SplitCostCycle(%+v) month %s - %s, previous end %s", cost, month.DateStart, month.DateEnd, dateEnd)
+			}
+			sum += conversion.USDtoCents(month.BlendedCostUSD)
+			dateEnd = month.DateEnd
+		}
+		if dateEnd != cost.DateEnd {
+			t.Fatalf("SplitCostCycle(%+v) months end at %s", cost, dateEnd)
+		}
+		if total := conversion.USDtoCents(cost.BlendedCostUSD); sum != total {
+			t.Fatalf("SplitCostCycle(%+v) months sum to %d cents, want %d", cost, sum, total)
+		}
+	}
+}
+
+// TestAccruedUSDSum 周期内任意切分点得到的各段美分之和恰好等于账单金额
+func TestAccruedUSDSum(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2000; i++ {
+		cycle := date.RecurringCycles[r.Intn(len(date.RecurringCycles))]
+		start := base.AddDate(0, 0, r.Intn(3000))
+		end := cycle.AddTo(start, 1)
+		cost := CostInfo{
+			DateStart:      start.Format("2006-01-02"),
+			DateEnd:        end.Format("2006-01-02"),
+			BlendedCostUSD: conversion.CentsToUSD(r.Int63n(1000000)),
+			CostCycle:      string(cycle),
+		}
+		days := int(end.Sub(start).Hours() / 24)
+		cuts := []string{cost.DateStart}
+		for offset := 0; offset < days; {
+			offset += 1 + r.Intn(days)
+			if offset > days {
+				offset = days
+			}
+			cuts = append(cuts, start.AddDate(0, 0, offset).Format("2006-01-02"))
+		}
+		var sum int64
+		for j := 1; j < len(cuts); j++ {
+			usd, err := AccruedUSD(cost, cuts[j-1], cuts[j])
+			if err != nil {
+				t.Fatalf("AccruedUSD(%+v, %s, %s) error: %v", cost, cuts[j-1], cuts[j], err)
+			}
+			sum += conversion.USDtoCents(usd)
+		}
+		if total := conversion.USDtoCents(cost.BlendedCostUSD); sum != total {
+			t.Fatalf("AccruedUSD(%+v) cuts %v sum to %d cents, want %d", cost, cuts, sum, total)
+		}
+	}
+}
+
+// 到期时间为月初时不输出 {DateEnd, DateEnd} 的空区间
+func TestSplitCostCycleFirstOfMonth(t *testing.T) {
+	cost := CostInfo{DateStart: "2023-01-01", DateEnd: "2023-04-01", BlendedCostUSD: 30, CostCycle: string(date.CycleQuarterly)}
+	var months [][2]string
+	for _, piece := range SplitCostCycle(cost) {
+		if piece.CostCycle == string(date.CycleMonthly) {
+			months = append(months, [2]string{piece.DateStart, piece.DateEnd})
+		}
+	}
+	want := [][2]string{{"2023-01-01", "2023-02-01"}, {"2023-02-01", "2023-03-01"}, {"2023-03-01", "2023-04-01"}}
+	if len(months) != len(want) {
+		t.Fatalf("SplitCostCycle months = %v, want %v", months, want)
+	}
+	for i := range want {
+		if months[i] != want[i] {
+			t.Errorf("SplitCostCycle month %d = %v, want %v", i, months[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package grab
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotLoggedIn 页面未处于登录状态, 会话已过期需要重新登录
+var ErrNotLoggedIn = errors.New("not logged in")
+
+// ErrHTTPStatus 页面返回非 200 状态码
+type ErrHTTPStatus struct {
+	Page string
+	Code int
+}
+
+func (e *ErrHTTPStatus) Error() string {
+	return fmt.Sprintf("%s page StatusCode not is 200, it is %d", e.Page, e.Code)
+}
+
+// ErrLayoutChanged 页面中找不到预期的元素, 通常是 vollcloud 页面改版
+type ErrLayoutChanged struct {
+	Page     string
+	Selector string
+}
+
+func (e *ErrLayoutChanged) Error() string {
+	return fmt.Sprintf("%s page layout changed, selector not found: %s", e.Page, e.Selector)
+}
+
+// ErrParse 页面字段内容无法解析
+type ErrParse struct {
+	Field string
+	Raw   string
+	Err   error
+}
+
+func (e *ErrParse) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("parse %s failed, raw: %q", e.Field, e.Raw)
+	}
+	return fmt.Sprintf("parse %s failed, raw: %q: %s", e.Field, e.Raw, e.Err.Error())
+}
+
+func (e *ErrParse) Unwrap() error {
+	return e.Err
+}
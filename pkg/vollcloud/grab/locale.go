@@ -0,0 +1,258 @@
+package grab
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/spf13/viper"
+
+	"vollcloud-exporter/pkg/unit/date"
+)
+
+// 面板支持英文和中文界面, 此处将两种语言的状态、付费周期、表头及日期统一为规范值
+
+// Status 产品 / 服务状态规范值
+type Status string
+
+const (
+	StatusUnknown    Status = "unknown"
+	StatusOnline     Status = "online"
+	StatusOffline    Status = "offline"
+	StatusActive     Status = "active"
+	StatusPending    Status = "pending"
+	StatusSuspended  Status = "suspended"
+	StatusTerminated Status = "terminated"
+	StatusCancelled  Status = "cancelled"
+)
+
+var statusWords = map[string]Status{
+	"online":     StatusOnline,
+	"running":    StatusOnline,
+	"在线":         StatusOnline,
+	"运行中":        StatusOnline,
+	"offline":    StatusOffline,
+	"stopped":    StatusOffline,
+	"离线":         StatusOffline,
+	"已关机":        StatusOffline,
+	"active":     StatusActive,
+	"有效的":        StatusActive,
+	"已激活":        StatusActive,
+	"pending":    StatusPending,
+	"待处理":        StatusPending,
+	"suspended":  StatusSuspended,
+	"已暂停":        StatusSuspended,
+	"terminated": StatusTerminated,
+	"已终止":        StatusTerminated,
+	"已删除":        StatusTerminated,
+	"cancelled":  StatusCancelled,
+	"已取消":        StatusCancelled,
+}
+
+// ParseStatus 状态文字转为规范值, 不区分大小写
+func ParseStatus(s string) Status {
+	if status, ok := statusWords[normalizeWord(s)]; ok {
+		return status
+	}
+	return StatusUnknown
+}
+
+var cycleWords = map[string]date.BillingCycle{
+	"monthly":       date.CycleMonthly,
+	"month":         date.CycleMonthly,
+	"每月":            date.CycleMonthly,
+	"月":             date.CycleMonthly,
+	"月付":            date.CycleMonthly,
+	"quarterly":     date.CycleQuarterly,
+	"quarter":       date.CycleQuarterly,
+	"每季度":           date.CycleQuarterly,
+	"季度":            date.CycleQuarterly,
+	"季付":            date.CycleQuarterly,
+	"semi-annually": date.CycleSemiAnnually,
+	"semiannually":  date.CycleSemiAnnually,
+	"semiannual":    date.CycleSemiAnnually,
+	"每半年":           date.CycleSemiAnnually,
+	"半年":            date.CycleSemiAnnually,
+	"半年付":           date.CycleSemiAnnually,
+	"annually":      date.CycleAnnually,
+	"year":          date.CycleAnnually,
+	"yearly":        date.CycleAnnually,
+	"每年":            date.CycleAnnually,
+	"年":             date.CycleAnnually,
+	"年付":            date.CycleAnnually,
+	"biennially":    date.CycleBiennially,
+	"biennial":      date.CycleBiennially,
+	"每两年":           date.CycleBiennially,
+	"两年":            date.CycleBiennially,
+	"triennially":   date.CycleTriennially,
+	"triennial":     date.CycleTriennially,
+	"每三年":           date.CycleTriennially,
+	"三年":            date.CycleTriennially,
+	"one time":      date.CycleOneTime,
+	"onetime":       date.CycleOneTime,
+	"free account":  date.CycleOneTime,
+	"一次性":           date.CycleOneTime,
+	"免费":            date.CycleOneTime,
+}
+
+// ParseCycle 付费周期文字转为规范值, 不区分大小写
+func ParseCycle(s string) date.BillingCycle {
+	if cycle, ok := cycleWords[normalizeWord(s)]; ok {
+		return cycle
+	}
+	return date.CycleUnknown
+}
+
+// Column 表格列规范名
+type Column string
+
+const (
+	ColumnUnknown      Column = ""
+	ColumnResourceName Column = "resource_name"
+	ColumnDescription  Column = "description"
+	ColumnIPv4         Column = "ipv4"
+	ColumnStatus       Column = "status"
+	ColumnDueDate      Column = "due_date"
+	ColumnCycle        Column = "cycle"
+	ColumnPrice        Column = "price"
+	ColumnActions      Column = "actions"
+)
+
+var columnWords = map[string]Column{
+	"资源名":                 ColumnResourceName,
+	"resource name":       ColumnResourceName,
+	"resource":            ColumnResourceName,
+	"产品/服务":               ColumnResourceName,
+	"product/service":     ColumnResourceName,
+	"产品描述":                ColumnDescription,
+	"description":         ColumnDescription,
+	"product description": ColumnDescription,
+	"ipv4":                ColumnIPv4,
+	"主ipv4地址":             ColumnIPv4,
+	"main ipv4 address":   ColumnIPv4,
+	"dedicated ip":        ColumnIPv4,
+	"状态":                  ColumnStatus,
+	"status":              ColumnStatus,
+	"到期时间":                ColumnDueDate,
+	"expiry date":         ColumnDueDate,
+	"due date":            ColumnDueDate,
+	"下次付款日期":              ColumnDueDate,
+	"next due date":       ColumnDueDate,
+	"续费周期":                ColumnCycle,
+	"付款周期":                ColumnCycle,
+	"billing cycle":       ColumnCycle,
+	"renewal cycle":       ColumnCycle,
+	"单价":                  ColumnPrice,
+	"价格":                  ColumnPrice,
+	"price":               ColumnPrice,
+	"amount":              ColumnPrice,
+	"recurring amount":    ColumnPrice,
+	"操作":                  ColumnActions,
+	"actions":             ColumnActions,
+	"action":              ColumnActions,
+}
+
+// ParseColumn 表头文字转为列规范名, 不区分大小写
+func ParseColumn(s string) Column {
+	if column, ok := columnWords[normalizeWord(s)]; ok {
+		return column
+	}
+	return ColumnUnknown
+}
+
+// fieldWords 产品详情页中文字段名对应的英文字段名, StatsMapTemp 统一使用英文字段名
+var fieldWords = map[string]string{
+	"类型":     "Type",
+	"节点":     "Nodename",
+	"节点名称":   "Nodename",
+	"主ip地址":  "Main IP Address",
+	"主机名":    "Hostname",
+	"状态":     "Status",
+	"内存":     "Memory",
+	"硬盘":     "HDD",
+	"磁盘":     "HDD",
+	"流量":     "Bandwidth",
+	"带宽":     "Bandwidth",
+	"ip地址":   "IP Addresses",
+	"root密码": "Root Password",
+	"ipv6地址": "IPv6 Address",
+	"cpu":    "CPU(s)",
+	"cpu核心":  "CPU(s)",
+	"操作系统":   "Operating System",
+	"模板":     "Template",
+	"位置":     "Location",
+	"注册日期":   "Registration Date",
+}
+
+// ParseField 产品详情页字段名统一为英文, 英文字段名原样返回
+func ParseField(s string) string {
+	s = strings.TrimSpace(s)
+	if field, ok := fieldWords[normalizeWord(s)]; ok {
+		return field
+	}
+	return s
+}
+
+// dateLayouts 年份在前或月份为文字、不存在歧义的日期格式
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"2006年1月2日",
+	"2 January 2006",
+	"2 Jan 2006",
+	"January 2 2006",
+	"Jan 2 2006",
+	"Monday January 2 2006",
+	"Mon Jan 2 2006",
+}
+
+// numericDateLayouts 日、月均为数字且年份在后的日期格式, 按 vollcloud.date_order 选择日在前或月在前,
+// 如 "01/02/2023" 在 dmy 下为 2 月 1 日, 在 mdy 下为 1 月 2 日
+var numericDateLayouts = map[string][]string{
+	"dmy": {"2/1/2006", "2.1.2006", "2-1-2006"},
+	"mdy": {"1/2/2006", "1.2.2006", "1-2-2006"},
+}
+
+var ordinalRegexp = regexp.MustCompile(`(\d)(?:st|nd|rd|th)\b`)
+
+// dateOrder 读取配置的数字日期顺序, 未配置或无法识别时为 dmy
+func dateOrder() string {
+	order := strings.ToLower(strings.TrimSpace(viper.GetString("vollcloud.date_order")))
+	if _, ok := numericDateLayouts[order]; ok {
+		return order
+	}
+	return "dmy"
+}
+
+// ParseDate 日期统一转为 "2006-01-02"
+func ParseDate(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	cleaned := strings.Join(strings.Fields(strings.ReplaceAll(ordinalRegexp.ReplaceAllString(s, "$1"), ",", " ")), " ")
+	for _, layout := range append(dateLayouts, numericDateLayouts[dateOrder()]...) {
+		if t, err := time.Parse(layout, cleaned); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", &ErrParse{Field: "date", Raw: s}
+}
+
+// normalizeWord 去除首尾空白及冒号并转为小写, 用于查表
+func normalizeWord(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimRight(s, ":：")
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// headerColumns 读取表格 thead 中的表头, 返回列规范名对应的 td 下标
+func headerColumns(table *goquery.Selection) map[Column]int {
+	columns := map[Column]int{}
+	table.Find("thead tr").First().Find("th").Each(func(i int, s *goquery.Selection) {
+		if column := ParseColumn(s.Text()); column != ColumnUnknown {
+			if _, ok := columns[column]; !ok {
+				columns[column] = i
+			}
+		}
+	})
+	return columns
+}
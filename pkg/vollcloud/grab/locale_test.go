@@ -0,0 +1,154 @@
+package grab
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"vollcloud-exporter/pkg/unit/date"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := map[string]Status{
+		"Online":       StatusOnline,
+		" running ":    StatusOnline,
+		"在线":           StatusOnline,
+		"Offline":      StatusOffline,
+		"已关机":          StatusOffline,
+		"Active":       StatusActive,
+		"有效的":          StatusActive,
+		"Pending":      StatusPending,
+		"待处理":          StatusPending,
+		"SUSPENDED":    StatusSuspended,
+		"已暂停":          StatusSuspended,
+		"Terminated":   StatusTerminated,
+		"已终止":          StatusTerminated,
+		"Cancelled":    StatusCancelled,
+		"已取消":          StatusCancelled,
+		"Status:":      StatusUnknown,
+		"":             StatusUnknown,
+		"Provisioning": StatusUnknown,
+	}
+	for in, want := range tests {
+		if got := ParseStatus(in); got != want {
+			t.Errorf("ParseStatus(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestParseCycle(t *testing.T) {
+	tests := map[string]date.BillingCycle{
+		"Monthly":       date.CycleMonthly,
+		"月付":            date.CycleMonthly,
+		"Quarterly":     date.CycleQuarterly,
+		"季付":            date.CycleQuarterly,
+		"Semi-Annually": date.CycleSemiAnnually,
+		"半年付":           date.CycleSemiAnnually,
+		"Annually":      date.CycleAnnually,
+		"年付":            date.CycleAnnually,
+		"Biennially":    date.CycleBiennially,
+		"两年":            date.CycleBiennially,
+		"Triennially":   date.CycleTriennially,
+		"三年":            date.CycleTriennially,
+		"One Time":      date.CycleOneTime,
+		"Free Account":  date.CycleOneTime,
+		"一次性":           date.CycleOneTime,
+		"Weekly":        date.CycleUnknown,
+		"":              date.CycleUnknown,
+	}
+	for in, want := range tests {
+		if got := ParseCycle(in); got != want {
+			t.Errorf("ParseCycle(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseColumn(t *testing.T) {
+	tests := map[string]Column{
+		"Resource Name":    ColumnResourceName,
+		"资源名":              ColumnResourceName,
+		"Description":      ColumnDescription,
+		"产品描述":             ColumnDescription,
+		"IPv4":             ColumnIPv4,
+		"主IPv4地址":          ColumnIPv4,
+		"Status":           ColumnStatus,
+		"状态":               ColumnStatus,
+		"Next Due Date":    ColumnDueDate,
+		"到期时间":             ColumnDueDate,
+		"Billing Cycle":    ColumnCycle,
+		"续费周期":             ColumnCycle,
+		"Recurring Amount": ColumnPrice,
+		"单价":               ColumnPrice,
+		"Actions":          ColumnActions,
+		"操作":               ColumnActions,
+		" Due  Date: ":     ColumnDueDate,
+		"Notes":            ColumnUnknown,
+	}
+	for in, want := range tests {
+		if got := ParseColumn(in); got != want {
+			t.Errorf("ParseColumn(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		order string
+		in    string
+		want  string
+	}{
+		{"", "2023-01-31", "2023-01-31"},
+		{"", "2023/01/31", "2023-01-31"},
+		{"", "2023年1月31日", "2023-01-31"},
+		{"", " 2023年12月1日 ", "2023-12-01"},
+		{"", "31 January 2023", "2023-01-31"},
+		{"", "31st Jan 2023", "2023-01-31"},
+		{"", "January 31st, 2023", "2023-01-31"},
+		{"", "Jan 31st, 2023", "2023-01-31"},
+		{"", "Tuesday, January 31st, 2023", "2023-01-31"},
+		{"", "Tue, Jan 31, 2023", "2023-01-31"},
+		{"", "31/01/2023", "2023-01-31"},
+		{"", "01/02/2023", "2023-02-01"},
+		{"dmy", "31.01.2023", "2023-01-31"},
+		{"dmy", "1-2-2023", "2023-02-01"},
+		{"mdy", "01/31/2023", "2023-01-31"},
+		{"mdy", "01/02/2023", "2023-01-02"},
+		{"MDY", "1.31.2023", "2023-01-31"},
+		{"mdy", "2023-01-31", "2023-01-31"},
+		{"mdy", "Jan 31st, 2023", "2023-01-31"},
+		{"invalid", "31/01/2023", "2023-01-31"},
+	}
+	t.Cleanup(func() { viper.Set("vollcloud.date_order", nil) })
+	for _, tt := range tests {
+		viper.Set("vollcloud.date_order", tt.order)
+		got, err := ParseDate(tt.in)
+		if err != nil {
+			t.Errorf("ParseDate(%q) date_order=%q error: %v", tt.in, tt.order, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDate(%q) date_order=%q = %s, want %s", tt.in, tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestParseDateError(t *testing.T) {
+	tests := []struct {
+		order string
+		in    string
+	}{
+		{"", ""},
+		{"", "-"},
+		{"", "N/A"},
+		{"", "01/31/2023"},
+		{"mdy", "31/01/2023"},
+		{"", "2023-02-30"},
+	}
+	t.Cleanup(func() { viper.Set("vollcloud.date_order", nil) })
+	for _, tt := range tests {
+		viper.Set("vollcloud.date_order", tt.order)
+		if got, err := ParseDate(tt.in); err == nil {
+			t.Errorf("ParseDate(%q) date_order=%q = %s, want error", tt.in, tt.order, got)
+		}
+	}
+}
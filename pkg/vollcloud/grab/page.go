@@ -0,0 +1,51 @@
+package grab
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"vollcloud-exporter/pkg/vollcloud/metrics"
+)
+
+// accountSelector 页头中已登录用户名所在元素, 所有登录后的页面都包含
+const accountSelector = ".nav-item.dropdown.account .nav-link.dropdown-toggle"
+
+// getDocument GET 页面并解析为 goquery.Document, 失败时计入 page_fetch_errors_total
+func getDocument(httpClient *http.Client, account, page, url string) (*goquery.Document, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		metrics.PageFetchError(account, page)
+		err = fmt.Errorf("Failed %s Get error: %s: %w", page, url, err)
+		log.Println(err.Error())
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		metrics.PageFetchError(account, page)
+		err = &ErrHTTPStatus{Page: page, Code: resp.StatusCode}
+		log.Println("Failed Get error: ", err.Error())
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		metrics.PageFetchError(account, page)
+		err = fmt.Errorf("Failed %s goquery error: %w", page, err)
+		log.Println(err.Error())
+		return nil, err
+	}
+	return doc, nil
+}
+
+// getUsername 页头中的登录用户名, 未登录时返回 ErrNotLoggedIn
+func getUsername(page string, doc *goquery.Document) (string, error) {
+	headerUsername := strings.TrimSpace(doc.Find(accountSelector).Text())
+	if len(headerUsername) == 0 {
+		pageTitleBox := doc.Find("title").Text() + doc.Find("div.pageError").Text()
+		return "", fmt.Errorf("Failed %s page %w: %s", page, ErrNotLoggedIn, strings.Fields(pageTitleBox))
+	}
+	return headerUsername, nil
+}
@@ -1,20 +1,24 @@
 package grab
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/spf13/viper"
+	"golang.org/x/net/html"
 
-	"vollcloud-exporter/pkg/unit/conversion"
+	"vollcloud-exporter/pkg/vollcloud/metrics"
 )
 
 type Productdetails struct {
 	HttpClient   *http.Client
+	Account      string
 	Doc          *goquery.Document
 	Stats        Stats
 	StatsMapTemp map[string]string
@@ -27,15 +31,33 @@ type Stats struct {
 	Type             string
 	Memory           string
 	Disk             string
+	MemoryBytes      float64
+	DiskBytes        float64
 	BandwidthTotalGB float64
 	BandwidthUsedGB  float64
 	BandwidthFreeGB  float64
 	BandwidthUsage   float64 // 使用百分比
+	// BandwidthUnlimited 不限流量套餐, 此时 BandwidthTotalGB / BandwidthFreeGB / BandwidthUsage 无意义
+	BandwidthUnlimited bool
+	Ipv6Address        string
+	ExtraIpAddresses   []string // 主 IP 以外的附加 IP
+	CpuCores           int
+	OsTemplate         string
+	Node               string // 节点名称 / 机房位置
+	RegistrationDate   string // 注册日期 2006-01-02
 }
 
-func NewProductdetails(httpClient http.Client) *Productdetails {
+// 产品详情页可能出现的字段名 (ParseField 统一后的英文字段名), 按优先级排列
+var (
+	ipv6Fields       = []string{"IPv6 Address", "Main IPv6 Address", "IPv6"}
+	osTemplateFields = []string{"Operating System", "Template", "OS"}
+	nodeFields       = []string{"Nodename", "Node", "Location"}
+)
+
+func NewProductdetails(httpClient http.Client, account string) *Productdetails {
 	return &Productdetails{
 		HttpClient:   &httpClient,
+		Account:      account,
 		Stats:        Stats{},
 		StatsMapTemp: map[string]string{},
 	}
@@ -44,23 +66,13 @@ func NewProductdetails(httpClient http.Client) *Productdetails {
 // Get 访问资源页面，获取资源描述.
 func (p *Productdetails) Get(idUrl string) error {
 	url := fmt.Sprintf("%s%s&language=english", viper.GetString("vollcloud.productdetails.url"), idUrl)
-	resp, err := p.HttpClient.Get(url)
+	doc, err := getDocument(p.HttpClient, p.Account, "productdetails", url)
 	if err != nil {
-		msg := fmt.Sprintf("Failed Productdetails Get error: %s %s", idUrl, err.Error())
-		log.Println(msg)
-		return fmt.Errorf(msg)
-	}
-	if resp.StatusCode != 200 {
-		msg := fmt.Sprintf("Failed Productdetails Get StatusCode not is 200, it is %v", resp.StatusCode)
-		log.Println(msg)
-		return fmt.Errorf(msg)
+		return err
 	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		msg := fmt.Sprintf("Failed Productdetails goquery error: %s", err.Error())
-		log.Println(msg)
-		return fmt.Errorf(msg)
+	if _, err := getUsername("productdetails", doc); err != nil {
+		log.Println(err.Error())
+		return err
 	}
 	p.Doc = doc
 	return nil
@@ -70,6 +82,8 @@ func (p *Productdetails) Get(idUrl string) error {
 func (p *Productdetails) GetModuleBody() {
 	p.Doc.Find("div.module-body .table.pm-stats tr").Each(func(i int, s *goquery.Selection) {
 		tds := []string{}
+		// 多个 IP 以 <br> 分隔时 Text() 会直接拼接, 先替换为换行
+		s.Find("td br").ReplaceWithHtml("\n")
 		s.Find("td").Each(func(i int, selection *goquery.Selection) {
 			if strings.TrimSpace(selection.Text()) == "" {
 				tds = append(tds, "nil")
@@ -79,7 +93,7 @@ func (p *Productdetails) GetModuleBody() {
 		})
 		if len(tds) >= 2 {
 			//log.Println("Info GetModuleBody", tds)
-			p.StatsMapTemp[tds[0]] = tds[1]
+			p.StatsMapTemp[ParseField(tds[0])] = tds[1]
 		}
 	})
 	hostname := strings.TrimSpace(p.Doc.Find("#solus-hostname").Text())
@@ -95,19 +109,29 @@ func (p *Productdetails) GetModuleBody() {
 	p.Doc.Find("div.svm-header-config div").Each(func(i int, s *goquery.Selection) {
 		conf := strings.Split(strings.TrimSpace(s.Text()), ":")
 		if len(conf) >= 2 {
-			p.StatsMapTemp[strings.TrimSpace(conf[0])] = strings.TrimSpace(conf[1])
+			p.StatsMapTemp[ParseField(conf[0])] = strings.TrimSpace(conf[1])
+		}
+	})
+	// WHMCS 产品概览: <h4>Registration Date</h4>Monday, January 2nd, 2023<h4>Recurring Amount</h4>...
+	// 同一个块中有多个 h4, 只取紧跟在 h4 之后的文本
+	p.Doc.Find("h4").Each(func(i int, s *goquery.Selection) {
+		if field := ParseField(s.Text()); field == "Registration Date" {
+			if value := followingText(s); len(value) != 0 {
+				p.StatsMapTemp[field] = value
+			}
 		}
 	})
 	//log.Println("Info GetModuleBody success: ", p.StatsMapTemp)
 }
 
-// CreateStats 将资源页面的信息进行统计拼凑
+// CreateStats 将资源页面的信息进行统计拼凑; 页面结构变化或流量无法解析时返回 error
 func (p *Productdetails) CreateStats() error {
 	p.GetModuleBody()
 	if len(p.StatsMapTemp) <= 2 {
-		msg := fmt.Sprintf("Failed CreateStats in GetModuleBody's StatsMapTemp is not to standard. StatsMapTemp: %s", p.StatsMapTemp)
-		log.Println(msg)
-		return fmt.Errorf(msg)
+		metrics.ParseError(p.Account, "module_body")
+		err := &ErrLayoutChanged{Page: "productdetails", Selector: "div.module-body .table.pm-stats tr"}
+		log.Println("Failed CreateStats ", err.Error(), "StatsMapTemp: ", p.StatsMapTemp)
+		return err
 	}
 	p.Stats.Hostname = p.StatsMapTemp["Hostname"]
 	p.Stats.IpAddress = p.StatsMapTemp["Main IP Address"]
@@ -115,61 +139,122 @@ func (p *Productdetails) CreateStats() error {
 	p.Stats.Type = p.StatsMapTemp["Type"]
 	p.Stats.Memory = p.StatsMapTemp["Memory"]
 	p.Stats.Disk = p.StatsMapTemp["HDD"]
+	p.getAttributes()
 	if b, ok := p.StatsMapTemp["Bandwidth"]; ok {
-		p.getBandwidth(b)
+		// 流量无法解析时产品按失败处理, 避免输出 0 流量及污染流量预测历史
+		if err := p.getBandwidth(b); err != nil {
+			var parseErr *ErrParse
+			if errors.As(err, &parseErr) {
+				metrics.ParseError(p.Account, parseErr.Field)
+			}
+			log.Println("Failed CreateStats getBandwidth ", err.Error())
+			return err
+		}
 	} else {
-		msg := fmt.Sprintf("Failed CreateStats in get StatsMapTemp[\"Bandwidth\"], key not exists")
-		log.Println(msg)
-		return fmt.Errorf(msg)
+		metrics.ParseError(p.Account, "bandwidth")
+		err := &ErrLayoutChanged{Page: "productdetails", Selector: "div.module-body .table.pm-stats tr Bandwidth"}
+		log.Println("Failed CreateStats ", err.Error())
+		return err
 	}
 	log.Println("Info CreateStats success: ", p.Stats)
 	return nil
 }
 
-// getBandwidth - b 例子: "254.38 GB of 1000 GB Used / 745.62 GB Free\n\n\n                                25%"
-func (p *Productdetails) getBandwidth(bandwidth string) {
-	sOf := strings.Split(bandwidth, " of ")
-	sUsed := strings.Split(sOf[1], " Used / ")
-	sFree := strings.Split(sUsed[1], " Free")
-	used := sOf[0]
-	total := sUsed[0]
-	free := sFree[0]
-	usage, _ := strconv.ParseFloat(strings.Split(strings.TrimSpace(sFree[1]), "%")[0], 64)
-	//log.Println(fmt.Sprintf("Info getBandwidth; used: %s, total: %s, free: %s, usage: %v", used, total, free, usage))
-
-	if usedGB, err := getConversion(used); err == nil {
-		p.Stats.BandwidthUsedGB = usedGB
-	}
-	if totalGB, err := getConversion(total); err == nil {
-		p.Stats.BandwidthTotalGB = totalGB
-	}
-	if freeGB, err := getConversion(free); err == nil {
-		p.Stats.BandwidthFreeGB = freeGB
-	}
-	p.Stats.BandwidthUsage = usage
+// getAttributes 解析 IPv6、附加 IP、内存/硬盘容量、CPU、系统模板、节点及注册日期, 页面没有的字段保持零值
+func (p *Productdetails) getAttributes() {
+	p.Stats.Ipv6Address = p.firstValue(ipv6Fields)
+	p.Stats.OsTemplate = p.firstValue(osTemplateFields)
+	p.Stats.Node = p.firstValue(nodeFields)
+	for _, ip := range strings.FieldsFunc(p.firstValue([]string{"IP Addresses"}), func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	}) {
+		if ip == p.Stats.IpAddress || ip == p.Stats.Ipv6Address || containsString(p.Stats.ExtraIpAddresses, ip) {
+			continue
+		}
+		if len(p.Stats.Ipv6Address) == 0 && strings.Contains(ip, ":") {
+			p.Stats.Ipv6Address = ip
+			continue
+		}
+		p.Stats.ExtraIpAddresses = append(p.Stats.ExtraIpAddresses, ip)
+	}
+	p.Stats.MemoryBytes = p.getSizeBytes("memory", p.Stats.Memory)
+	p.Stats.DiskBytes = p.getSizeBytes("disk", p.Stats.Disk)
+	if cpu := p.firstValue([]string{"CPU(s)"}); len(cpu) != 0 {
+		cores, err := strconv.Atoi(cpu)
+		if err != nil {
+			metrics.ParseError(p.Account, "cpu_cores")
+			log.Println("Failed getAttributes CPU(s)", (&ErrParse{Field: "cpu_cores", Raw: cpu, Err: err}).Error())
+		}
+		p.Stats.CpuCores = cores
+	}
+	if registration := p.firstValue([]string{"Registration Date"}); len(registration) != 0 {
+		registrationDate, err := ParseDate(registration)
+		if err != nil {
+			metrics.ParseError(p.Account, "registration_date")
+			log.Println("Failed getAttributes Registration Date", err.Error())
+		}
+		p.Stats.RegistrationDate = registrationDate
+	}
 }
 
-func getConversion(s string) (float64, error) {
-	ss := strings.Fields(s)
-	n, err := strconv.ParseFloat(ss[0], 64)
+// getSizeBytes 容量描述转为字节, 页面没有该字段或无法解析时返回 0
+func (p *Productdetails) getSizeBytes(field, value string) float64 {
+	if len(value) == 0 || value == "nil" {
+		return 0
+	}
+	bytes, err := ParseSizeBytes(field, value)
 	if err != nil {
-		log.Println("Failed getConversion string to ParseFloat error:", s, err.Error())
-		return n, fmt.Errorf("Failed getConversion string to ParseFloat error: %v, %s", s, err.Error())
+		metrics.ParseError(p.Account, field)
+		log.Println("Failed getSizeBytes", err.Error())
+	}
+	return bytes
+}
+
+// followingText 元素之后、下一个元素之前的文本
+func followingText(s *goquery.Selection) string {
+	var b strings.Builder
+	for node := s.Get(0).NextSibling; node != nil && node.Type == html.TextNode; node = node.NextSibling {
+		b.WriteString(node.Data)
 	}
-	if ss[1] == "GB" {
-		return n, nil
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// firstValue 按顺序返回 StatsMapTemp 中第一个非空字段的值
+func (p *Productdetails) firstValue(fields []string) string {
+	for _, field := range fields {
+		if value, ok := p.StatsMapTemp[field]; ok && value != "nil" && len(value) != 0 {
+			return value
+		}
 	}
-	if ss[1] == "MB" {
-		return conversion.MBtoGB(n), nil
+	return ""
+}
+
+// getBandwidth - b 例子: "254.38 GB of 1000 GB Used / 745.62 GB Free\n\n\n                                25%"
+func (p *Productdetails) getBandwidth(b string) error {
+	bandwidth, err := ParseBandwidth(b)
+	if err != nil {
+		return err
 	}
-	if ss[1] == "TB" {
-		return conversion.TBtoGB(n), nil
+	p.Stats.BandwidthUsedGB = bandwidth.UsedGB
+	p.Stats.BandwidthTotalGB = bandwidth.TotalGB
+	p.Stats.BandwidthFreeGB = bandwidth.FreeGB
+	p.Stats.BandwidthUsage = bandwidth.Usage
+	p.Stats.BandwidthUnlimited = bandwidth.Unlimited
+	return nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
 	}
-	return n, fmt.Errorf("Failed getConversion to get size type no existent, %v", s)
+	return false
 }
 
+// getStatus 在线返回 1, 其它状态返回 0
 func getStatus(s string) float64 {
-	if s == "online" || s == "Online" {
+	if ParseStatus(s) == StatusOnline {
 		return 1
 	}
 	return 0
@@ -0,0 +1,153 @@
+package grab
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// productdetailsPage 产品详情页结构: pm-stats 表格各行及 WHMCS 产品概览块
+func productdetailsPage(rows [][2]string, overview string) string {
+	var b strings.Builder
+	b.WriteString(`<html><body><span id="solus-hostname">hk1.example.com</span><span id="solus_status">online</span>`)
+	b.WriteString(`<div class="module-body"><table class="table pm-stats"><tbody>`)
+	for _, row := range rows {
+		b.WriteString("<tr><td>" + row[0] + "</td><td>" + row[1] + "</td></tr>")
+	}
+	b.WriteString("</tbody></table></div>")
+	b.WriteString(overview)
+	b.WriteString("</body></html>")
+	return b.String()
+}
+
+func parseProductdetails(t *testing.T, page string) *Productdetails {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Productdetails{Account: "test", Doc: doc, StatsMapTemp: map[string]string{}}
+	if err := p.CreateStats(); err != nil {
+		t.Fatalf("CreateStats error: %v", err)
+	}
+	return p
+}
+
+// whmcsOverview WHMCS 默认模板的产品概览, 同一个块中有多个 h4
+const whmcsOverview = `<div class="row"><div class="col-md-6 text-center">
+    <h4>Registration Date</h4>
+    Monday, January 30th, 2023
+    <h4>Recurring Amount</h4>
+    $5.00 USD
+    <h4>Billing Cycle</h4>
+    Monthly
+</div></div>`
+
+func TestCreateStatsAttributes(t *testing.T) {
+	tests := []struct {
+		name         string
+		rows         [][2]string
+		overview     string
+		ipv6         string
+		extra        []string
+		osTemplate   string
+		node         string
+		registration string
+	}{
+		{"english",
+			[][2]string{
+				{"Type", "kvm"},
+				{"Nodename", "HongKong"},
+				{"Main IP Address", "3.3.3.3"},
+				{"IP Addresses", "3.3.3.3, 4.4.4.4, 2001:db8::1, 5.5.5.5, 4.4.4.4"},
+				{"IPv6 Address", "2001:db8::1"},
+				{"Operating System", "Debian 11 64bit"},
+				{"Bandwidth", "10 GB of 1000 GB Used"},
+			},
+			whmcsOverview,
+			"2001:db8::1", []string{"4.4.4.4", "5.5.5.5"}, "Debian 11 64bit", "HongKong", "2023-01-30"},
+		{"ipv6 only in ip addresses",
+			[][2]string{
+				{"Main IP Address", "3.3.3.3"},
+				{"IP Addresses", "3.3.3.3<br>2001:db8::2<br/>2001:db8::3<br>4.4.4.4"},
+				{"Template", "ubuntu-22.04-x86_64"},
+				{"Location", "Tokyo"},
+				{"Bandwidth", "10 GB of 1000 GB Used"},
+			},
+			`<div><h4>Registration Date</h4>2022-12-01</div>`,
+			"2001:db8::2", []string{"2001:db8::3", "4.4.4.4"}, "ubuntu-22.04-x86_64", "Tokyo", "2022-12-01"},
+		{"chinese",
+			[][2]string{
+				{"类型", "kvm"},
+				{"节点", "香港"},
+				{"主IP地址", "3.3.3.3"},
+				{"IP地址", "3.3.3.3;4.4.4.4"},
+				{"IPv6地址", "2001:db8::1"},
+				{"操作系统", "CentOS 7"},
+				{"流量", "254.38 GB 共 1000 GB 已用 / 745.62 GB 剩余 25%"},
+			},
+			`<div><h4>注册日期</h4>2023年1月30日<h4>下次付款日期</h4>2023年3月30日</div>`,
+			"2001:db8::1", []string{"4.4.4.4"}, "CentOS 7", "香港", "2023-01-30"},
+		{"missing attributes",
+			[][2]string{
+				{"Main IP Address", "3.3.3.3"},
+				{"IP Addresses", ""},
+				{"Bandwidth", "10 GB of 1000 GB Used"},
+			},
+			"",
+			"", nil, "", "", ""},
+		{"unparsable registration date",
+			[][2]string{
+				{"Main IP Address", "3.3.3.3"},
+				{"Bandwidth", "10 GB of 1000 GB Used"},
+			},
+			`<div><h4>Registration Date</h4>-</div>`,
+			"", nil, "", "", ""},
+	}
+	for _, tt := range tests {
+		stats := parseProductdetails(t, productdetailsPage(tt.rows, tt.overview)).Stats
+		if stats.IpAddress != "3.3.3.3" {
+			t.Errorf("%s: IpAddress = %q, want 3.3.3.3", tt.name, stats.IpAddress)
+		}
+		if stats.Ipv6Address != tt.ipv6 {
+			t.Errorf("%s: Ipv6Address = %q, want %q", tt.name, stats.Ipv6Address, tt.ipv6)
+		}
+		if strings.Join(stats.ExtraIpAddresses, ",") != strings.Join(tt.extra, ",") {
+			t.Errorf("%s: ExtraIpAddresses = %q, want %q", tt.name, stats.ExtraIpAddresses, tt.extra)
+		}
+		if stats.OsTemplate != tt.osTemplate {
+			t.Errorf("%s: OsTemplate = %q, want %q", tt.name, stats.OsTemplate, tt.osTemplate)
+		}
+		if stats.Node != tt.node {
+			t.Errorf("%s: Node = %q, want %q", tt.name, stats.Node, tt.node)
+		}
+		if stats.RegistrationDate != tt.registration {
+			t.Errorf("%s: RegistrationDate = %q, want %q", tt.name, stats.RegistrationDate, tt.registration)
+		}
+	}
+}
+
+// 在抓取样例中填入附加 IP 及注册日期, 确认真实页面结构下同样可以解析
+func TestCreateStatsFixtureAttributes(t *testing.T) {
+	data, err := os.ReadFile("../../../docs/example/productdetails.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := regexp.MustCompile(`(?s)(<td>IP Addresses</td>\s*<td>)\s*(</td>)`).
+		ReplaceAllString(string(data), "${1}3.3.3.3<br />2001:db8::10<br />6.6.6.6${2}")
+	page = strings.Replace(page, `<div class="svm-header-config">`,
+		`<div class="col-md-6 text-center"><h4>Registration Date</h4>Monday, January 30th, 2023<h4>Recurring Amount</h4>$5.00 USD</div><div class="svm-header-config">`, 1)
+	stats := parseProductdetails(t, page).Stats
+	if stats.IpAddress != "3.3.3.3" || stats.Ipv6Address != "2001:db8::10" || strings.Join(stats.ExtraIpAddresses, ",") != "6.6.6.6" {
+		t.Errorf("IP = %q / %q / %q, want 3.3.3.3 / 2001:db8::10 / [6.6.6.6]", stats.IpAddress, stats.Ipv6Address, stats.ExtraIpAddresses)
+	}
+	if stats.RegistrationDate != "2023-01-30" {
+		t.Errorf("RegistrationDate = %q, want 2023-01-30", stats.RegistrationDate)
+	}
+	if stats.Node != "HongKong" || stats.Type != "kvm" {
+		t.Errorf("Node = %q, Type = %q, want HongKong, kvm", stats.Node, stats.Type)
+	}
+}
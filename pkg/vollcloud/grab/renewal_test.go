@@ -0,0 +1,168 @@
+package grab
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"vollcloud-exporter/pkg/unit/date"
+)
+
+// renewalPage 续费页面结构, 表头与各行按 th / td 列表拼接
+func renewalPage(headers []string, rows ...[]string) string {
+	var b strings.Builder
+	b.WriteString(`<html><body><div class="renewal-bd-table"><table class="table"><thead><tr>`)
+	for _, header := range headers {
+		fmt.Fprintf(&b, "<th>%s</th>", header)
+	}
+	b.WriteString("</tr></thead><tbody>")
+	for _, row := range rows {
+		b.WriteString("<tr>")
+		for _, cell := range row {
+			if strings.HasPrefix(cell, "<td") {
+				b.WriteString(cell)
+				continue
+			}
+			fmt.Fprintf(&b, "<td>%s</td>", cell)
+		}
+		b.WriteString("</tr>")
+	}
+	b.WriteString("</tbody></table></div></body></html>")
+	return b.String()
+}
+
+func renewLink(sid string) string {
+	return fmt.Sprintf(`<a class="text-primary" href="https://vollcloud.com/index.php?m=renewal&amp;action=renew&amp;sid=%s">Renew</a>`, sid)
+}
+
+func parseRenewals(t *testing.T, page string) (*Cost, error) {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Cost{Account: "test", Doc: doc}
+	return c, c.GetRenewalEntries()
+}
+
+func TestGetRenewalEntriesColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		row     []string
+	}{
+		{"chinese fixture order",
+			[]string{"", "资源名", "产品描述", "IPv4", "到期时间", "续费周期", "单价", "操作"},
+			[]string{"", "HK-Group 13", "hk1.example.com", "3.3.3.3", "2023-08-11", "每年", "$149.00 USD", renewLink("3112")}},
+		{"english reordered",
+			[]string{"Actions", "Price", "Billing Cycle", "Next Due Date", "IPv4", "Description", "Resource Name"},
+			[]string{renewLink("3112"), "$149.00 USD", "Annually", "11/08/2023", "3.3.3.3", "hk1.example.com", "HK-Group 13"}},
+		{"extra columns",
+			[]string{"Status", "Resource Name", "Notes", "Description", "IPv4", "Due Date", "Auto Renew", "Billing Cycle", "Amount", "Actions"},
+			[]string{"Active", "HK-Group 13", "-", "hk1.example.com", "3.3.3.3", "2023-08-11", "off", "Annually", "$149.00 USD", renewLink("3112")}},
+	}
+	want := RenewalEntry{
+		ProductId:    "3112",
+		ResourceName: "HK-Group 13",
+		Description:  "hk1.example.com",
+		IPv4:         "3.3.3.3",
+		DueDate:      "2023-08-11",
+		Cycle:        date.CycleAnnually,
+		PriceUSD:     149,
+	}
+	for _, tt := range tests {
+		c, err := parseRenewals(t, renewalPage(tt.headers, tt.row))
+		if err != nil {
+			t.Errorf("%s: GetRenewalEntries error: %v", tt.name, err)
+			continue
+		}
+		if len(c.Entries) != 1 || c.Entries[0] != want {
+			t.Errorf("%s: entries = %+v, want [%+v]", tt.name, c.Entries, want)
+		}
+	}
+}
+
+// 只有必需列时其余字段为空
+func TestGetRenewalEntriesRequiredOnly(t *testing.T) {
+	c, err := parseRenewals(t, renewalPage(
+		[]string{"Due Date", "Billing Cycle", "Price", "Actions"},
+		[]string{"2023-08-11", "Monthly", "$5.00 USD", renewLink("3112")},
+	))
+	if err != nil {
+		t.Fatalf("GetRenewalEntries error: %v", err)
+	}
+	want := RenewalEntry{ProductId: "3112", DueDate: "2023-08-11", Cycle: date.CycleMonthly, PriceUSD: 5}
+	if len(c.Entries) != 1 || c.Entries[0] != want {
+		t.Errorf("entries = %+v, want [%+v]", c.Entries, want)
+	}
+}
+
+func TestGetRenewalEntriesMissingColumn(t *testing.T) {
+	headers := map[Column]string{
+		ColumnResourceName: "Resource Name",
+		ColumnDueDate:      "Due Date",
+		ColumnCycle:        "Billing Cycle",
+		ColumnPrice:        "Price",
+		ColumnActions:      "Actions",
+	}
+	for _, missing := range costRequiredColumns {
+		var page []string
+		for _, column := range []Column{ColumnResourceName, ColumnDueDate, ColumnCycle, ColumnPrice, ColumnActions} {
+			if column != missing {
+				page = append(page, headers[column])
+			}
+		}
+		_, err := parseRenewals(t, renewalPage(page, []string{"a", "b", "c", "d"}))
+		var layoutErr *ErrLayoutChanged
+		if !errors.As(err, &layoutErr) {
+			t.Errorf("without %s: error = %v, want *ErrLayoutChanged", missing, err)
+			continue
+		}
+		if layoutErr.Page != "cost" || !strings.Contains(layoutErr.Selector, string(missing)) {
+			t.Errorf("without %s: error = %+v", missing, layoutErr)
+		}
+	}
+
+	_, err := parseRenewals(t, "<html><body><p>maintenance</p></body></html>")
+	var layoutErr *ErrLayoutChanged
+	if !errors.As(err, &layoutErr) {
+		t.Errorf("without table: error = %v, want *ErrLayoutChanged", err)
+	}
+}
+
+// 空状态提示及没有续费链接的行不输出条目
+func TestGetRenewalEntriesSkipRowsWithoutProduct(t *testing.T) {
+	headers := []string{"Resource Name", "Due Date", "Billing Cycle", "Price", "Actions"}
+	tests := []struct {
+		name string
+		rows [][]string
+		want []string
+	}{
+		{"empty state", [][]string{{`<td colspan="5" class="text-center">No Records Found</td>`}}, nil},
+		{"chinese empty state", [][]string{{`<td colspan="5">暂无记录</td>`}}, nil},
+		{"no link", [][]string{
+			{"HK-Group 13", "2023-08-11", "Annually", "$149.00 USD", ""},
+			{"HK-Group 12", "2023-09-19", "Annually", "$89.00 USD", renewLink("3161")},
+		}, []string{"3161"}},
+		{"link without sid", [][]string{
+			{"HK-Group 13", "2023-08-11", "Annually", "$149.00 USD", `<a href="https://vollcloud.com/index.php?m=renewal">Renew</a>`},
+		}, nil},
+	}
+	for _, tt := range tests {
+		c, err := parseRenewals(t, renewalPage(headers, tt.rows...))
+		if err != nil {
+			t.Errorf("%s: GetRenewalEntries error: %v", tt.name, err)
+			continue
+		}
+		var ids []string
+		for _, entry := range c.Entries {
+			ids = append(ids, entry.ProductId)
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: product ids = %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+}
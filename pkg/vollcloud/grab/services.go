@@ -7,49 +7,62 @@ import (
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/spf13/viper"
+
+	"vollcloud-exporter/pkg/vollcloud/metrics"
 )
 
 type Services struct {
 	HttpClient *http.Client
+	Account    string
 	Doc        *goquery.Document
 	IdUrls     []string
 }
 
-func NewServices(httpClient http.Client) *Services {
+func NewServices(httpClient http.Client, account string) *Services {
 	return &Services{
 		HttpClient: &httpClient,
+		Account:    account,
 		IdUrls:     []string{},
 	}
 }
 
 // Get 获取 services 页面
-func (s *Services) Get() {
+func (s *Services) Get() error {
 	url := viper.GetString("vollcloud.services.url")
-	resp, err := s.HttpClient.Get(url)
+	doc, err := getDocument(s.HttpClient, s.Account, "services", url)
 	if err != nil {
-		log.Println("Failed Services Get error: ", err.Error())
-		return
+		return err
 	}
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		log.Println("Failed Services goquery error: ", err.Error())
-		return
+	if _, err := getUsername("services", doc); err != nil {
+		log.Println(err.Error())
+		return err
 	}
 	s.Doc = doc
+	return nil
 }
 
 // GetProductIdUrls 获取资源的子页面
-func (s *Services) GetProductIdUrls() {
-	urlHref := s.Doc.Find("#tableServicesList tbody tr").Each(func(i int, gs *goquery.Selection) {
+func (s *Services) GetProductIdUrls() error {
+	table := s.Doc.Find("#tableServicesList")
+	if table.Length() == 0 {
+		metrics.ParseError(s.Account, "services_table")
+		err := &ErrLayoutChanged{Page: "services", Selector: "#tableServicesList"}
+		log.Println("Failed GetProductIdUrls() ", err.Error())
+		return err
+	}
+	urlHref := table.Find("tbody tr").Each(func(i int, gs *goquery.Selection) {
 		onclick, IsExist := gs.Attr("onclick")
 		if IsExist {
 			onclicks := strings.Split(onclick, "'")
-			if len(onclick) < 2 {
+			if len(onclicks) < 2 {
+				metrics.ParseError(s.Account, "product_id_url")
 				log.Println("Warn GetProductIdUrls() unusual Split: ", onclick)
+				return
 			}
 			s.IdUrls = append(s.IdUrls, onclicks[1])
 		}
 		log.Println("Info GetProductIdUrls() : ", onclick, IsExist)
 	})
 	log.Println("Info GetProductIdUrls() IdUrls: ", urlHref.Size(), s.IdUrls)
+	return nil
 }
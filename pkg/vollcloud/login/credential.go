@@ -0,0 +1,127 @@
+package login
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// CredentialProvider 登录密码来源; 实现不得在日志或错误信息中输出密码内容
+type CredentialProvider interface {
+	Password() (string, error)
+}
+
+// StaticCredential 配置文件中明文的 password, 兼容旧配置
+type StaticCredential string
+
+func (c StaticCredential) Password() (string, error) {
+	if len(c) == 0 {
+		return "", fmt.Errorf("Failed Credential password is empty")
+	}
+	return string(c), nil
+}
+
+// EnvCredential 从环境变量读取密码
+type EnvCredential struct {
+	Name string
+}
+
+func (c EnvCredential) Password() (string, error) {
+	password, ok := os.LookupEnv(c.Name)
+	if !ok || len(password) == 0 {
+		return "", fmt.Errorf("Failed Credential env %s is not set", c.Name)
+	}
+	return password, nil
+}
+
+// FileCredential 从文件读取密码, 适用于 systemd LoadCredential 及 Kubernetes secret 挂载
+type FileCredential struct {
+	Path string
+}
+
+func (c FileCredential) Password() (string, error) {
+	data, err := os.ReadFile(c.Path)
+	if err != nil {
+		return "", fmt.Errorf("Failed Credential read password_file %s: %s", c.Path, err.Error())
+	}
+	password := strings.TrimRight(string(data), "\r\n")
+	if len(password) == 0 {
+		return "", fmt.Errorf("Failed Credential password_file %s is empty", c.Path)
+	}
+	return password, nil
+}
+
+// CommandCredential 执行外部命令, 以标准输出作为密码
+type CommandCredential struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (c CommandCredential) Password() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	cmd := shellCommand(ctx, c.Command)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	// 超时只会结束 shell 本身, 子进程仍持有标准输出时 Run 会一直等待, 因此单独等待超时
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Run()
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	// 命令参数中可能带有密钥, 错误信息只包含可执行文件名及退出状态, 不包含完整命令及标准输出
+	if err != nil {
+		return "", fmt.Errorf("Failed Credential password_command %s: %s", commandName(c.Command), err.Error())
+	}
+	password := strings.TrimRight(stdout.String(), "\r\n")
+	if len(password) == 0 {
+		return "", fmt.Errorf("Failed Credential password_command %s output is empty", commandName(c.Command))
+	}
+	return password, nil
+}
+
+// shellCommand 通过系统 shell 执行命令, 支持管道等写法; Windows 使用 cmd /C
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// commandName 命令中的可执行文件名, 用于日志
+func commandName(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return `""`
+	}
+	return filepath.Base(fields[0])
+}
+
+// CredentialProvider 按 password_command > password_file > password_env > password 的优先级选择密码来源
+func (a Account) CredentialProvider(timeout time.Duration) CredentialProvider {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if len(a.PasswordCommand) != 0 {
+		return CommandCredential{Command: a.PasswordCommand, Timeout: timeout}
+	}
+	if len(a.PasswordFile) != 0 {
+		return FileCredential{Path: a.PasswordFile}
+	}
+	if len(a.PasswordEnv) != 0 {
+		return EnvCredential{Name: a.PasswordEnv}
+	}
+	return StaticCredential(a.Password)
+}
@@ -0,0 +1,133 @@
+package login
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCredentialProviderPrecedence(t *testing.T) {
+	full := Account{Password: "p", PasswordEnv: "E", PasswordFile: "f", PasswordCommand: "c"}
+	tests := []struct {
+		name    string
+		account Account
+		want    CredentialProvider
+	}{
+		{"command", full, CommandCredential{Command: "c", Timeout: 5 * time.Second}},
+		{"file", Account{Password: "p", PasswordEnv: "E", PasswordFile: "f"}, FileCredential{Path: "f"}},
+		{"env", Account{Password: "p", PasswordEnv: "E"}, EnvCredential{Name: "E"}},
+		{"password", Account{Password: "p"}, StaticCredential("p")},
+		{"empty", Account{}, StaticCredential("")},
+	}
+	for _, tt := range tests {
+		if got := tt.account.CredentialProvider(5 * time.Second); got != tt.want {
+			t.Errorf("%s: CredentialProvider = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+	if got := full.CredentialProvider(0).(CommandCredential).Timeout; got != 10*time.Second {
+		t.Errorf("default command timeout = %s, want 10s", got)
+	}
+}
+
+func TestStaticCredential(t *testing.T) {
+	if password, err := StaticCredential("secret").Password(); err != nil || password != "secret" {
+		t.Errorf("Password = %q, %v, want secret", password, err)
+	}
+	if _, err := StaticCredential("").Password(); err == nil {
+		t.Error("empty password error = nil")
+	}
+}
+
+func TestEnvCredential(t *testing.T) {
+	t.Setenv("VOLLCLOUD_TEST_PASSWORD", "env secret")
+	t.Setenv("VOLLCLOUD_TEST_EMPTY", "")
+	if password, err := (EnvCredential{Name: "VOLLCLOUD_TEST_PASSWORD"}).Password(); err != nil || password != "env secret" {
+		t.Errorf("Password = %q, %v, want env secret", password, err)
+	}
+	for _, name := range []string{"VOLLCLOUD_TEST_EMPTY", "VOLLCLOUD_TEST_UNSET"} {
+		if _, err := (EnvCredential{Name: name}).Password(); err == nil {
+			t.Errorf("%s error = nil", name)
+		}
+	}
+}
+
+func TestFileCredential(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"secret", "secret"},
+		{"secret\n", "secret"},
+		{"secret\r\n", "secret"},
+		{"secret\n\n", "secret"},
+		{" pass word \n", " pass word "},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, "password"+string(rune('a'+i)))
+		if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if password, err := (FileCredential{Path: path}).Password(); err != nil || password != tt.want {
+			t.Errorf("Password(%q) = %q, %v, want %q", tt.content, password, err, tt.want)
+		}
+	}
+	for _, content := range []string{"", "\n", "\r\n"} {
+		path := filepath.Join(dir, "empty")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := (FileCredential{Path: path}).Password(); err == nil {
+			t.Errorf("Password(%q) error = nil", content)
+		}
+	}
+	if _, err := (FileCredential{Path: filepath.Join(dir, "missing")}).Password(); err == nil {
+		t.Error("missing file error = nil")
+	}
+}
+
+func TestCommandCredential(t *testing.T) {
+	password, err := (CommandCredential{Command: "echo secret", Timeout: 5 * time.Second}).Password()
+	if err != nil || password != "secret" {
+		t.Errorf("Password = %q, %v, want secret", password, err)
+	}
+	if _, err := (CommandCredential{Command: "exit 0", Timeout: 5 * time.Second}).Password(); err == nil {
+		t.Error("empty output error = nil")
+	}
+	_, err = (CommandCredential{Command: "vollcloud-missing-command --token=topsecret", Timeout: 5 * time.Second}).Password()
+	if err == nil {
+		t.Fatal("failing command error = nil")
+	}
+	if strings.Contains(err.Error(), "topsecret") || !strings.Contains(err.Error(), "vollcloud-missing-command") {
+		t.Errorf("error = %q, want only the executable name", err.Error())
+	}
+}
+
+func TestCommandCredentialTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available in cmd")
+	}
+	start := time.Now()
+	if _, err := (CommandCredential{Command: "sleep 2", Timeout: 100 * time.Millisecond}).Password(); err == nil {
+		t.Error("timeout error = nil")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Password returned after %s, want the timeout to kill the command", elapsed)
+	}
+}
+
+func TestCommandName(t *testing.T) {
+	for command, want := range map[string]string{
+		"pass show vollcloud":                  "pass",
+		"/usr/bin/op read op://vault/password": "op",
+		"  vault kv get -field=password x  ":   "vault",
+		"":                                     `""`,
+	} {
+		if got := commandName(command); got != want {
+			t.Errorf("commandName(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
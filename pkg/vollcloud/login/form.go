@@ -0,0 +1,54 @@
+package login
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// formHiddenValues 提取表单中所有 hidden input, 提交时原样带回 (如 WHMCS 的 token)
+func formHiddenValues(form *goquery.Selection) url.Values {
+	values := url.Values{}
+	form.Find("input[type=hidden]").Each(func(i int, s *goquery.Selection) {
+		name, ok := s.Attr("name")
+		if !ok || len(name) == 0 {
+			return
+		}
+		value, _ := s.Attr("value")
+		values.Add(name, value)
+	})
+	return values
+}
+
+// formActionUrl 表单提交地址, 相对 action 以页面地址为基准解析, 无 action 时提交到页面本身
+func formActionUrl(pageUrl *url.URL, form *goquery.Selection) (string, error) {
+	action, _ := form.Attr("action")
+	u, err := pageUrl.Parse(action)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// ErrCaptchaRequired 登录页面出现验证码 (captcha / reCAPTCHA), 程序无法自动处理, 调用方应暂停重试登录
+var ErrCaptchaRequired = errors.New("Failed Login captcha required")
+
+// captchaSelector WHMCS 内置图片验证码、reCAPTCHA 及 hCaptcha 的页面元素
+const captchaSelector = `.g-recaptcha, .h-captcha, #google-recaptcha, iframe[src*="recaptcha"], ` +
+	`textarea[name="g-recaptcha-response"], img[src*="verifyimage"], #inputCaptchaImage, input[name="code"]`
+
+// loginForm 登录页面中包含 password 输入框的表单
+func loginForm(doc *goquery.Document) *goquery.Selection {
+	return doc.Find("form").FilterFunction(func(i int, s *goquery.Selection) bool {
+		return s.Find("input[name=password]").Length() > 0
+	}).First()
+}
+
+// captchaRequired 判断页面是否要求验证码; 优先在登录表单内查找, 无登录表单时查找整个页面
+func captchaRequired(doc *goquery.Document) bool {
+	if form := loginForm(doc); form.Length() > 0 {
+		return form.Find(captchaSelector).Length() > 0
+	}
+	return doc.Find(captchaSelector).Length() > 0
+}
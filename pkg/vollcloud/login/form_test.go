@@ -0,0 +1,126 @@
+package login
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/spf13/viper"
+)
+
+const whmcsLoginPage = `<html><body>
+<form method="post" action="/index.php/login" class="login-form">
+    <input type="hidden" name="token" value="csrf-123">
+    <input type="hidden" name="rp" value="/clientarea.php">
+    <input type="email" name="username" value="">
+    <input type="password" name="password">
+    %s
+</form>
+</body></html>`
+
+func newDocument(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("goquery error: %v", err)
+	}
+	return doc
+}
+
+func TestFormHiddenValues(t *testing.T) {
+	doc := newDocument(t, strings.Replace(whmcsLoginPage, "%s", "", 1))
+	values := formHiddenValues(loginForm(doc))
+	want := url.Values{"token": {"csrf-123"}, "rp": {"/clientarea.php"}}
+	if values.Encode() != want.Encode() {
+		t.Errorf("formHiddenValues = %v, want %v", values, want)
+	}
+}
+
+func TestFormActionUrl(t *testing.T) {
+	pageUrl, _ := url.Parse("https://vollcloud.com/index.php/login")
+	tests := []struct {
+		form string
+		want string
+	}{
+		{`<form action="/index.php/login">`, "https://vollcloud.com/index.php/login"},
+		{`<form action="dologin.php">`, "https://vollcloud.com/index.php/dologin.php"},
+		{`<form>`, "https://vollcloud.com/index.php/login"},
+	}
+	for _, tt := range tests {
+		doc := newDocument(t, tt.form+`<input name="password"></form>`)
+		got, err := formActionUrl(pageUrl, doc.Find("form"))
+		if err != nil || got != tt.want {
+			t.Errorf("formActionUrl(%s) = %s, %v, want %s", tt.form, got, err, tt.want)
+		}
+	}
+}
+
+func TestCaptchaRequired(t *testing.T) {
+	tests := []struct {
+		name    string
+		captcha string
+		want    bool
+	}{
+		{"none", "", false},
+		{"whmcs image", `<img src="/includes/verifyimage.php"><input type="text" name="code">`, true},
+		{"recaptcha", `<div class="g-recaptcha" data-sitekey="x"></div>`, true},
+		{"hcaptcha", `<div class="h-captcha"></div>`, true},
+	}
+	for _, tt := range tests {
+		doc := newDocument(t, strings.Replace(whmcsLoginPage, "%s", tt.captcha, 1))
+		if got := captchaRequired(doc); got != tt.want {
+			t.Errorf("%s: captchaRequired = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// docs/example/login.html 为登录后的页面, 页面脚本中含 recaptchaSiteKey 但没有验证码元素
+func TestCaptchaRequiredFixture(t *testing.T) {
+	html, err := os.ReadFile("../../../docs/example/login.html")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	doc := newDocument(t, string(html))
+	if captchaRequired(doc) {
+		t.Error("captchaRequired(login.html) = true, want false")
+	}
+	if loginForm(doc).Length() != 0 {
+		t.Error("loginForm(login.html) found a login form on the logged-in page")
+	}
+}
+
+// 模拟面板校验 CSRF token, 只有带回登录页 hidden token 的提交才能登录
+func TestPrepareFormCarriesToken(t *testing.T) {
+	panel := newTestPanel(t)
+	vcLogin := NewLogin(testAccount(panel))
+	actionUrl, values, err := vcLogin.prepareForm(url.Values{"username": {testUsername}, "password": {testPassword}})
+	if err != nil {
+		t.Fatalf("prepareForm error: %v", err)
+	}
+	if actionUrl != panel.URL+"/index.php/login" {
+		t.Errorf("prepareForm action = %s, want %s", actionUrl, panel.URL+"/index.php/login")
+	}
+	if len(values.Get("token")) == 0 {
+		t.Error("prepareForm did not carry the hidden token")
+	}
+	if values.Get("username") != testUsername || values.Get("password") != testPassword {
+		t.Errorf("prepareForm credentials = %v", values)
+	}
+}
+
+func TestLoginCaptchaRequired(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Replace(whmcsLoginPage, "%s", `<div class="g-recaptcha"></div>`, 1)))
+	}))
+	defer server.Close()
+	viper.Set("vollcloud.login.url", server.URL+"/index.php/login")
+	_, err := NewLogin(Account{Name: "test", Username: testUsername, Password: testPassword}).Login()
+	if !errors.Is(err, ErrCaptchaRequired) {
+		t.Fatalf("Login error = %v, want ErrCaptchaRequired", err)
+	}
+}
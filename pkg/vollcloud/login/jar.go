@@ -0,0 +1,85 @@
+package login
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// sessionJar 在 cookiejar 之上记录服务端下发 cookie 的完整属性 (Domain/Path/Secure/HttpOnly/Expires),
+// cookiejar.Cookies() 只返回 Name/Value, 会话持久化需要这些属性才能按原样恢复
+type sessionJar struct {
+	*cookiejar.Jar
+
+	mutex   sync.Mutex
+	cookies map[string]*http.Cookie // key: name;domain;path
+}
+
+func newSessionJar() *sessionJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &sessionJar{Jar: jar, cookies: map[string]*http.Cookie{}}
+}
+
+// SetCookies 写入 cookiejar 并记录属性; Max-Age 换算为 Expires, 已过期或 Max-Age<0 的 cookie 删除记录
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	now := time.Now()
+	for _, cookie := range cookies {
+		saved := *cookie
+		saved.Raw, saved.RawExpires, saved.Unparsed = "", "", nil
+		// 没有 Domain 属性的 cookie 只属于当前 host, 恢复时同样不设置 Domain
+		saved.Domain = strings.TrimPrefix(strings.ToLower(saved.Domain), ".")
+		if len(saved.Path) == 0 || saved.Path[0] != '/' {
+			saved.Path = defaultCookiePath(u.Path)
+		}
+		if saved.MaxAge > 0 {
+			saved.Expires = now.Add(time.Duration(saved.MaxAge) * time.Second)
+			saved.MaxAge = 0
+		}
+		key := saved.Name + ";" + saved.Domain + ";" + saved.Path
+		if cookie.MaxAge < 0 || (!saved.Expires.IsZero() && !saved.Expires.After(now)) {
+			delete(j.cookies, key)
+			continue
+		}
+		j.cookies[key] = &saved
+	}
+}
+
+// savedCookies 记录中尚未过期的 cookie
+func (j *sessionJar) savedCookies() []*http.Cookie {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	now := time.Now()
+	var cookies []*http.Cookie
+	for _, cookie := range j.cookies {
+		if !cookie.Expires.IsZero() && !cookie.Expires.After(now) {
+			continue
+		}
+		saved := *cookie
+		cookies = append(cookies, &saved)
+	}
+	sort.Slice(cookies, func(a, b int) bool {
+		return cookies[a].Name+";"+cookies[a].Domain+";"+cookies[a].Path < cookies[b].Name+";"+cookies[b].Domain+";"+cookies[b].Path
+	})
+	return cookies
+}
+
+// defaultCookiePath RFC 6265 5.1.4 默认 cookie 路径, 与 cookiejar 的处理一致
+func defaultCookiePath(path string) string {
+	if len(path) == 0 || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strconv"
 	"strings"
@@ -12,50 +11,143 @@ import (
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/spf13/viper"
-	"golang.org/x/net/publicsuffix"
 )
 
 type Login struct {
 	Url        string
-	UrlValues  url.Values
+	UrlValues  url.Values // 登录表单账号, 密码在 Login 时由 Credential 提供
+	Credential CredentialProvider
 	Timeout    time.Duration
 	HttpClient *http.Client
+	TotpSecret string // 两步验证 base32 密钥, 为空时不支持两步验证
 }
 
-func NewLogin() *Login {
+// Account vollcloud 登录账号, 对应配置 vollcloud.accounts
+type Account struct {
+	Name     string `mapstructure:"name"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// PasswordEnv / PasswordFile / PasswordCommand 替代明文 password 的密码来源, 见 CredentialProvider
+	PasswordEnv     string `mapstructure:"password_env"`
+	PasswordFile    string `mapstructure:"password_file"`
+	PasswordCommand string `mapstructure:"password_command"`
+	// TotpSecret 两步验证 (TOTP) 的 base32 密钥, 账号未开启两步验证时留空
+	TotpSecret string `mapstructure:"totp_secret"`
+}
+
+// GetAccounts 读取配置中的账号列表; 未配置 vollcloud.accounts 时兼容旧的 vollcloud.login 单账号配置
+func GetAccounts() ([]Account, error) {
+	var accounts []Account
+	if err := viper.UnmarshalKey("vollcloud.accounts", &accounts); err != nil {
+		return accounts, fmt.Errorf("Failed GetAccounts unmarshal vollcloud.accounts: %s", err.Error())
+	}
+	if len(accounts) == 0 {
+		accounts = append(accounts, Account{
+			Name:            "default",
+			Username:        viper.GetString("vollcloud.login.username"),
+			Password:        viper.GetString("vollcloud.login.password"),
+			PasswordEnv:     viper.GetString("vollcloud.login.password_env"),
+			PasswordFile:    viper.GetString("vollcloud.login.password_file"),
+			PasswordCommand: viper.GetString("vollcloud.login.password_command"),
+			TotpSecret:      viper.GetString("vollcloud.login.totp_secret"),
+		})
+	}
+	names := map[string]bool{}
+	for i, account := range accounts {
+		if len(account.Name) == 0 {
+			accounts[i].Name = account.Username
+		}
+		if names[accounts[i].Name] {
+			return accounts, fmt.Errorf("Failed GetAccounts duplicate account name: %s", accounts[i].Name)
+		}
+		names[accounts[i].Name] = true
+	}
+	return accounts, nil
+}
+
+// String 只输出账号名及用户名, 避免密码等敏感信息出现在日志中
+func (a Account) String() string {
+	return fmt.Sprintf("{Name:%s Username:%s}", a.Name, a.Username)
+}
+
+func NewLogin(account Account) *Login {
 	loginUrl := viper.GetString("vollcloud.login.url")
-	username := viper.GetString("vollcloud.login.username")
-	password := viper.GetString("vollcloud.login.password")
+	username := account.Username
 	timeout := viper.GetString("vollcloud.timeout")
 	timeout64, _ := strconv.ParseInt(timeout, 10, 64)
 	urlValues := url.Values{
 		"username": []string{username},
-		"password": []string{password},
-	}
-	options := cookiejar.Options{
-		PublicSuffixList: publicsuffix.List,
 	}
-	jar, _ := cookiejar.New(&options)
 	client := &http.Client{
-		Jar:     jar,
+		Jar:     newSessionJar(),
 		Timeout: time.Duration(timeout64) * time.Second,
 	}
 	return &Login{
 		Url:        loginUrl,
 		UrlValues:  urlValues,
+		Credential: account.CredentialProvider(time.Duration(timeout64) * time.Second),
 		Timeout:    time.Duration(timeout64) * time.Second,
 		HttpClient: client,
+		TotpSecret: account.TotpSecret,
 	}
 }
 
+// prepareForm 先 GET 登录页面, 带上表单中的 hidden input (WHMCS CSRF token) 及账号密码, 返回提交地址
+func (l *Login) prepareForm(credentials url.Values) (string, url.Values, error) {
+	resp, err := l.HttpClient.Get(l.Url)
+	if err != nil {
+		log.Println("Failed Login form Get err: ", err.Error())
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		log.Println("Failed goquery error: ", err)
+		return "", nil, err
+	}
+	if captchaRequired(doc) {
+		return "", nil, ErrCaptchaRequired
+	}
+	form := loginForm(doc)
+	if form.Length() == 0 {
+		log.Println("Warn Login form not found, post credentials only: ", l.Url)
+		return l.Url, credentials, nil
+	}
+	actionUrl, err := formActionUrl(resp.Request.URL, form)
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed Login form action: %s", err.Error())
+	}
+	values := formHiddenValues(form)
+	for key, value := range credentials {
+		values[key] = value
+	}
+	return actionUrl, values, nil
+}
+
 // Login res username, error
 func (l *Login) Login() (string, error) {
 	client := l.HttpClient
-	resp, err := client.PostForm(l.Url, l.UrlValues)
+	password, err := l.Credential.Password()
+	if err != nil {
+		log.Println("Failed Login credential: ", err.Error())
+		return "", err
+	}
+	credentials := url.Values{}
+	for key, value := range l.UrlValues {
+		credentials[key] = value
+	}
+	credentials.Set("password", password)
+	actionUrl, values, err := l.prepareForm(credentials)
+	if err != nil {
+		log.Println("Failed Login form: ", err.Error())
+		return "", err
+	}
+	resp, err := client.PostForm(actionUrl, values)
 	if err != nil {
 		log.Println("Failed Login in err: ", err.Error())
 		return "", err
 	}
+	defer resp.Body.Close()
 	//body, _ := ioutil.ReadAll(resp.Body)
 	//fmt.Println(string(body))
 
@@ -64,11 +156,23 @@ func (l *Login) Login() (string, error) {
 		log.Println("Failed goquery error: ", err)
 		return "", err
 	}
+	if form := twoFactorForm(doc); form.Length() > 0 {
+		log.Println("Info login two-factor authentication required")
+		doc, err = l.twoFactor(resp.Request.URL, form)
+		if err != nil {
+			log.Println("Failed Login two-factor: ", err.Error())
+			return "", err
+		}
+	}
 	//fmt.Println(doc.Text(), doc.Find(".nav-item.dropdown.account").Text(), doc.Find(".nav-item.dropdown.account .nav-link.dropdown-toggle").Text())
 	headerUsername := strings.TrimSpace(doc.Find(".nav-item.dropdown.account .nav-link.dropdown-toggle").Text())
 
 	log.Println("Info login success user: ", headerUsername)
 	if len(headerUsername) == 0 {
+		if captchaRequired(doc) {
+			log.Println("Failed Login, captcha required")
+			return "", ErrCaptchaRequired
+		}
 		pageTitleBox := doc.Find("title").Text() + doc.Find("div.pageError").Text() + doc.Find("#MGAlerts").Text()
 		log.Println("Failed Login, msg: ", strings.Fields(pageTitleBox))
 		return "", fmt.Errorf("Failed Login %s ", strings.Fields(pageTitleBox))
@@ -0,0 +1,54 @@
+package login
+
+import (
+	"testing"
+
+	"vollcloud-exporter/pkg/vollcloud/fakepanel"
+)
+
+const (
+	testUsername = "user@example.com"
+	testPassword = "secret"
+)
+
+// newTestPanel 启动使用 docs/example 页面的模拟面板, 并将 viper 中的面板地址指向它
+func newTestPanel(t *testing.T) *fakepanel.Server {
+	t.Helper()
+	panel, err := fakepanel.NewServer("../../../docs/example", testUsername, testPassword)
+	if err != nil {
+		t.Fatalf("fakepanel.NewServer error: %v", err)
+	}
+	t.Cleanup(panel.Close)
+	panel.Configure()
+	return panel
+}
+
+func testAccount(panel *fakepanel.Server) Account {
+	return Account{Name: "test", Username: panel.Username, Password: panel.Password}
+}
+
+func TestLogin(t *testing.T) {
+	panel := newTestPanel(t)
+	username, err := NewLogin(testAccount(panel)).Login()
+	if err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+	if len(username) == 0 {
+		t.Error("Login returned empty header username")
+	}
+	if panel.LoginCount() != 1 {
+		t.Errorf("LoginCount = %d, want 1", panel.LoginCount())
+	}
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	panel := newTestPanel(t)
+	account := testAccount(panel)
+	account.Password = "wrong"
+	if _, err := NewLogin(account).Login(); err == nil {
+		t.Fatal("Login with wrong password expected error")
+	}
+	if panel.LoginCount() != 0 {
+		t.Errorf("LoginCount = %d, want 0", panel.LoginCount())
+	}
+}
@@ -0,0 +1,100 @@
+package login
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// GetSessionFile 账号会话 cookie 的持久化文件, 未配置 vollcloud.session.dir 时返回空, 不做持久化
+func GetSessionFile(account Account) string {
+	dir := viper.GetString("vollcloud.session.dir")
+	if len(dir) == 0 {
+		return ""
+	}
+	return filepath.Join(dir, url.PathEscape(account.Name)+".json")
+}
+
+// SaveSession 将 cookie jar 中站点的 cookie 连同 Domain/Path/Secure/HttpOnly/Expires 属性写入文件, 文件权限 0600
+func (l *Login) SaveSession(path string) error {
+	var cookies []*http.Cookie
+	if jar, ok := l.HttpClient.Jar.(*sessionJar); ok {
+		cookies = jar.savedCookies()
+	} else {
+		// 其它 cookie jar 只能取得 Name/Value, 恢复后为当前 host 的非 Secure cookie
+		siteUrl, err := l.siteUrl()
+		if err != nil {
+			return err
+		}
+		cookies = l.HttpClient.Jar.Cookies(siteUrl)
+	}
+	data, err := json.Marshal(cookies)
+	if err != nil {
+		return fmt.Errorf("Failed SaveSession marshal cookies: %s", err.Error())
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("Failed SaveSession mkdir: %s", err.Error())
+	}
+	// 先写临时文件再 rename, 避免进程中断留下不完整的会话文件
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("Failed SaveSession create file: %s", err.Error())
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Failed SaveSession chmod: %s", err.Error())
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Failed SaveSession write: %s", err.Error())
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Failed SaveSession close: %s", err.Error())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("Failed SaveSession rename: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadSession 从文件恢复 cookie 到 cookie jar, 已过期的 cookie 不会恢复; 会话是否仍然有效需调用方通过 clientarea 校验
+func (l *Login) LoadSession(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Failed LoadSession read: %s", err.Error())
+	}
+	var cookies []*http.Cookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return fmt.Errorf("Failed LoadSession unmarshal cookies: %s", err.Error())
+	}
+	if len(cookies) == 0 {
+		return fmt.Errorf("Failed LoadSession no cookies in %s", path)
+	}
+	siteUrl, err := l.siteUrl()
+	if err != nil {
+		return err
+	}
+	for _, cookie := range cookies {
+		// 旧版本会话文件只有 Name/Value
+		if len(cookie.Path) == 0 {
+			cookie.Path = "/"
+		}
+	}
+	l.HttpClient.Jar.SetCookies(siteUrl, cookies)
+	return nil
+}
+
+// siteUrl 登录 url 所在站点根路径, cookie 以站点为单位保存
+func (l *Login) siteUrl() (*url.URL, error) {
+	u, err := url.Parse(l.Url)
+	if err != nil {
+		return nil, fmt.Errorf("Failed parse login url: %s", err.Error())
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}, nil
+}
@@ -0,0 +1,168 @@
+package login
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"vollcloud-exporter/pkg/vollcloud/grab"
+)
+
+// 登录后保存会话, 新的 Login 恢复会话即可访问用户中心, 不需要重新登录
+func TestSessionRoundTrip(t *testing.T) {
+	panel := newTestPanel(t)
+	account := testAccount(panel)
+	path := filepath.Join(t.TempDir(), "session", "test.json")
+
+	l := NewLogin(account)
+	if _, err := l.Login(); err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+	if err := l.SaveSession(path); err != nil {
+		t.Fatalf("SaveSession error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("session file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	restored := NewLogin(account)
+	if err := restored.LoadSession(path); err != nil {
+		t.Fatalf("LoadSession error: %v", err)
+	}
+	clientarea := grab.NewClientarea(*restored.HttpClient, account.Name)
+	if err := clientarea.Get(); err != nil {
+		t.Fatalf("clientarea Get error: %v", err)
+	}
+	if _, err := clientarea.IfUserLogin(); err != nil {
+		t.Errorf("restored session IfUserLogin error: %v", err)
+	}
+	if panel.LoginCount() != 1 {
+		t.Errorf("LoginCount = %d, want 1 (the restored session must not log in again)", panel.LoginCount())
+	}
+}
+
+func TestGetSessionFile(t *testing.T) {
+	t.Cleanup(func() { viper.Set("vollcloud.session.dir", nil) })
+	viper.Set("vollcloud.session.dir", "")
+	if file := GetSessionFile(Account{Name: "test"}); file != "" {
+		t.Errorf("GetSessionFile without dir = %q, want empty", file)
+	}
+	viper.Set("vollcloud.session.dir", "session")
+	if file := GetSessionFile(Account{Name: "a/b"}); file != filepath.Join("session", "a%2Fb.json") {
+		t.Errorf("GetSessionFile = %q, want the account name escaped", file)
+	}
+}
+
+// 会话文件保留 Domain/Path/Secure/HttpOnly/Expires, 恢复后 Secure cookie 不会通过 http 发送
+func TestSessionCookieAttributes(t *testing.T) {
+	site, _ := url.Parse("https://panel.example.com/index.php/login")
+	jar := newSessionJar()
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	jar.SetCookies(site, []*http.Cookie{
+		{Name: "WHMCSsession", Value: "s1", Path: "/", Secure: true, HttpOnly: true},
+		{Name: "remember", Value: "r1", Domain: ".example.com", Path: "/", Expires: expires},
+		{Name: "maxage", Value: "m1", Path: "/", MaxAge: 3600},
+		{Name: "login", Value: "l1"},
+		{Name: "expired", Value: "e1", Path: "/", Expires: time.Now().Add(-time.Hour)},
+	})
+	jar.SetCookies(site, []*http.Cookie{{Name: "maxage", Value: "", Path: "/", MaxAge: -1}})
+
+	l := &Login{Url: site.String(), HttpClient: &http.Client{Jar: jar}}
+	path := filepath.Join(t.TempDir(), "test.json")
+	if err := l.SaveSession(path); err != nil {
+		t.Fatalf("SaveSession error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved []*http.Cookie
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]*http.Cookie{}
+	for _, cookie := range saved {
+		byName[cookie.Name] = cookie
+	}
+	if len(saved) != 3 || byName["expired"] != nil || byName["maxage"] != nil {
+		t.Fatalf("saved cookies = %s, want WHMCSsession, login, remember", data)
+	}
+	if c := byName["WHMCSsession"]; !c.Secure || !c.HttpOnly || c.Domain != "" {
+		t.Errorf("WHMCSsession = %+v, want host-only Secure HttpOnly", c)
+	}
+	if c := byName["remember"]; c.Domain != "example.com" || !c.Expires.Equal(expires) {
+		t.Errorf("remember = %+v, want domain example.com expires %s", c, expires)
+	}
+	if c := byName["login"]; c.Path != "/index.php" {
+		t.Errorf("login path = %q, want the default path /index.php", c.Path)
+	}
+
+	restored := &Login{Url: site.String(), HttpClient: &http.Client{Jar: newSessionJar()}}
+	if err := restored.LoadSession(path); err != nil {
+		t.Fatalf("LoadSession error: %v", err)
+	}
+	names := func(rawUrl string) string {
+		u, _ := url.Parse(rawUrl)
+		var names []string
+		for _, cookie := range restored.HttpClient.Jar.Cookies(u) {
+			names = append(names, cookie.Name)
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+	for rawUrl, want := range map[string]string{
+		"https://panel.example.com/clientarea.php":     "WHMCSsession,remember",
+		"https://panel.example.com/index.php/login":    "WHMCSsession,login,remember",
+		"http://panel.example.com/clientarea.php":      "remember",
+		"https://www.example.com/":                     "remember",
+		"https://panel.example.com.evil.test/anything": "",
+	} {
+		if got := names(rawUrl); got != want {
+			t.Errorf("restored cookies for %s = %q, want %q", rawUrl, got, want)
+		}
+	}
+}
+
+// 旧版本会话文件只有 Name/Value, 恢复为站点根路径的 cookie
+func TestLoadSessionLegacyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.json")
+	if err := os.WriteFile(path, []byte(`[{"Name":"WHMCSsession","Value":"s1"}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	l := &Login{Url: "https://panel.example.com/index.php/login", HttpClient: &http.Client{Jar: newSessionJar()}}
+	if err := l.LoadSession(path); err != nil {
+		t.Fatalf("LoadSession error: %v", err)
+	}
+	u, _ := url.Parse("http://panel.example.com/clientarea.php")
+	if cookies := l.HttpClient.Jar.Cookies(u); len(cookies) != 1 || cookies[0].Value != "s1" {
+		t.Errorf("restored cookies = %v, want WHMCSsession=s1", cookies)
+	}
+}
+
+func TestLoadSessionError(t *testing.T) {
+	dir := t.TempDir()
+	l := &Login{Url: "https://panel.example.com/index.php/login", HttpClient: &http.Client{Jar: newSessionJar()}}
+	for name, content := range map[string]string{"empty.json": "[]", "invalid.json": "{"} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0600)
+		if err := l.LoadSession(path); err == nil {
+			t.Errorf("LoadSession(%s) error = nil", name)
+		}
+	}
+	if err := l.LoadSession(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadSession(missing) error = nil")
+	}
+}
@@ -0,0 +1,59 @@
+package login
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"vollcloud-exporter/pkg/unit/totp"
+)
+
+var (
+	// ErrTwoFactorRequired 账号开启了两步验证, 但未配置 totp_secret
+	ErrTwoFactorRequired = errors.New("Failed Login two-factor authentication required, totp_secret not configured")
+	// ErrTwoFactorRejected 两步验证码提交后被拒绝
+	ErrTwoFactorRejected = errors.New("Failed Login two-factor authentication code rejected")
+)
+
+// twoFactorForm 登录后的 WHMCS 两步验证页面表单, 验证码输入框 name="key"
+func twoFactorForm(doc *goquery.Document) *goquery.Selection {
+	return doc.Find("form").FilterFunction(func(i int, s *goquery.Selection) bool {
+		return s.Find("input[name=key]").Length() > 0 && s.Find("input[type=password]").Length() == 0
+	})
+}
+
+// twoFactor 根据 TotpSecret 生成验证码并提交两步验证表单, 返回验证后的页面
+func (l *Login) twoFactor(pageUrl *url.URL, form *goquery.Selection) (*goquery.Document, error) {
+	if len(l.TotpSecret) == 0 {
+		return nil, ErrTwoFactorRequired
+	}
+	code, err := totp.GenerateCode(l.TotpSecret, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	actionUrl, err := formActionUrl(pageUrl, form.First())
+	if err != nil {
+		return nil, fmt.Errorf("Failed Login two-factor form action: %s", err.Error())
+	}
+	values := formHiddenValues(form.First())
+	values.Set("key", code)
+	resp, err := l.HttpClient.PostForm(actionUrl, values)
+	if err != nil {
+		log.Println("Failed Login two-factor in err: ", err.Error())
+		return nil, err
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		log.Println("Failed goquery error: ", err)
+		return nil, err
+	}
+	if twoFactorForm(doc).Length() > 0 {
+		return nil, ErrTwoFactorRejected
+	}
+	return doc, nil
+}
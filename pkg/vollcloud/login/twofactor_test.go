@@ -0,0 +1,50 @@
+package login
+
+import (
+	"errors"
+	"testing"
+
+	"vollcloud-exporter/pkg/vollcloud/fakepanel"
+)
+
+const testTotpSecret = "JBSWY3DPEHPK3PXP"
+
+func newTwoFactorPanel(t *testing.T) *fakepanel.Server {
+	t.Helper()
+	panel := newTestPanel(t)
+	panel.TotpSecret = testTotpSecret
+	return panel
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	panel := newTwoFactorPanel(t)
+	account := testAccount(panel)
+	account.TotpSecret = testTotpSecret
+	if _, err := NewLogin(account).Login(); err != nil {
+		t.Fatalf("Login with two-factor error: %v", err)
+	}
+	if panel.LoginCount() != 1 {
+		t.Errorf("LoginCount = %d, want 1", panel.LoginCount())
+	}
+}
+
+func TestLoginTwoFactorRejected(t *testing.T) {
+	panel := newTwoFactorPanel(t)
+	account := testAccount(panel)
+	account.TotpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	_, err := NewLogin(account).Login()
+	if !errors.Is(err, ErrTwoFactorRejected) {
+		t.Fatalf("Login with wrong totp secret error = %v, want ErrTwoFactorRejected", err)
+	}
+	if panel.LoginCount() != 0 {
+		t.Errorf("LoginCount = %d, want 0", panel.LoginCount())
+	}
+}
+
+func TestLoginTwoFactorRequired(t *testing.T) {
+	panel := newTwoFactorPanel(t)
+	_, err := NewLogin(testAccount(panel)).Login()
+	if !errors.Is(err, ErrTwoFactorRequired) {
+		t.Fatalf("Login without totp secret error = %v, want ErrTwoFactorRequired", err)
+	}
+}
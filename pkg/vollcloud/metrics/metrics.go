@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "vollcloud"
+
+// exporter 自身健康状况指标, 由 scrape 与 grab 在抓取过程中更新
+var (
+	ScrapeDurationSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "scrape_duration_seconds",
+			Help:      "最近一次后台抓取耗时 second",
+		}, []string{"account"})
+	ScrapeSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "scrape_success",
+			Help:      "最近一次后台抓取是否全部成功, Failed=0 / Success=1",
+		}, []string{"account"})
+	LoginTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "login_total",
+			Help:      "登录次数, result=success|failure|captcha_required",
+		}, []string{"account", "result"})
+	PageFetchErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "page_fetch_errors_total",
+			Help:      "页面获取失败次数, page=services|productdetails|cost|clientarea",
+		}, []string{"account", "page"})
+	ParseErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "parse_errors_total",
+			Help:      "页面字段解析失败次数",
+		}, []string{"account", "field"})
+)
+
+// Collectors 返回所有需要注册的自身健康指标
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		ScrapeDurationSeconds,
+		ScrapeSuccess,
+		LoginTotal,
+		PageFetchErrorsTotal,
+		ParseErrorsTotal,
+	}
+}
+
+// PageFetchError 记录一次页面获取失败
+func PageFetchError(account, page string) {
+	PageFetchErrorsTotal.WithLabelValues(account, page).Inc()
+}
+
+// ParseError 记录一次字段解析失败
+func ParseError(account, field string) {
+	ParseErrorsTotal.WithLabelValues(account, field).Inc()
+}
@@ -0,0 +1,129 @@
+package scrape
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"vollcloud-exporter/pkg/unit/date"
+	"vollcloud-exporter/pkg/vollcloud/fakepanel"
+	"vollcloud-exporter/pkg/vollcloud/grab"
+)
+
+func TestSetBandwidthReset(t *testing.T) {
+	now := time.Date(2023, 3, 15, 12, 0, 0, 0, time.UTC)
+	snapshot := &Snapshot{
+		Renewals: []grab.RenewalEntry{
+			{ProductId: "1001", DueDate: "2023-06-20", Cycle: date.CycleAnnually},
+			{ProductId: "1001", DueDate: "2023-04-01", Cycle: date.CycleMonthly},
+			{ProductId: "1002", DueDate: "2023-01-31", Cycle: date.CycleMonthly},
+			{ProductId: "1003", DueDate: "2023-03-15", Cycle: date.CycleQuarterly},
+			{ProductId: "1004", Cycle: date.CycleMonthly},
+			{ProductId: "1005", DueDate: "15/03/2023", Cycle: date.CycleMonthly},
+		},
+		Products: []Product{
+			{ProductId: "1001"}, {ProductId: "1002"}, {ProductId: "1003"},
+			{ProductId: "1004"}, {ProductId: "1005"}, {ProductId: "1006"},
+		},
+	}
+	setBandwidthReset(snapshot, now)
+	want := map[string][2]string{
+		// 同一产品多条续费记录时使用第一条
+		"1001": {"2023-02-20", "2023-03-20"},
+		// 月末续费日期在短月份按月末重置
+		"1002": {"2023-02-28", "2023-03-31"},
+		// 重置当天进入新周期
+		"1003": {"2023-03-15", "2023-04-15"},
+		// 没有可用的续费日期时不猜测自然月
+		"1004": {},
+		"1005": {},
+		"1006": {},
+	}
+	for _, product := range snapshot.Products {
+		period := want[product.ProductId]
+		if period == ([2]string{}) {
+			if !product.ResetStart.IsZero() || !product.ResetEnd.IsZero() {
+				t.Errorf("product %s reset = %s - %s, want zero", product.ProductId, product.ResetStart, product.ResetEnd)
+			}
+			continue
+		}
+		start, end := product.ResetStart.Format("2006-01-02"), product.ResetEnd.Format("2006-01-02")
+		if start != period[0] || end != period[1] {
+			t.Errorf("product %s reset = %s - %s, want %s - %s", product.ProductId, start, end, period[0], period[1])
+		}
+	}
+}
+
+func TestSetBandwidthResetNoRenewals(t *testing.T) {
+	snapshot := &Snapshot{Products: []Product{{ProductId: "1001"}, {ProductId: "1002"}}}
+	setBandwidthReset(snapshot, time.Now())
+	for _, product := range snapshot.Products {
+		if !product.ResetStart.IsZero() || !product.ResetEnd.IsZero() {
+			t.Errorf("product %s reset = %s - %s, want zero", product.ProductId, product.ResetStart, product.ResetEnd)
+		}
+	}
+}
+
+// 成本页面暂时异常时沿用上一次的续费信息, 流量重置时间不变, 发布 Success=false 的 Snapshot
+func TestRefreshKeepsRenewalsOnCostFault(t *testing.T) {
+	panel, scraper := newTestScraper(t)
+	// 样例中续费记录的产品 ID 均已脱敏为 3333, 将第一条关联到服务列表中的产品 3112 (到期时间 2023-08-11)
+	cost, err := os.ReadFile("../../../docs/example/cost.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	panel.SetFixture(fakepanel.PageCost, []byte(strings.Replace(string(cost), "sid=3333", "sid=3112", 1)))
+
+	previous := refreshOK(t, scraper)
+	want := productById(previous, "3112").ResetEnd
+	if want.IsZero() || want.Day() != 11 {
+		t.Fatalf("product 3112 reset end = %s, want the 11th of a month", want)
+	}
+	if other := productById(previous, "3266").ResetEnd; !other.IsZero() {
+		t.Errorf("product 3266 reset end = %s, want zero without renewal", other)
+	}
+
+	panel.SetFault(fakepanel.PageCost, fakepanel.Fault{Status: http.StatusBadGateway})
+	snapshot := scraper.Refresh()
+	if snapshot.Success {
+		t.Error("Refresh Success = true, want false")
+	}
+	if scraper.Snapshot() != snapshot {
+		t.Error("Refresh did not publish the snapshot with fresh products")
+	}
+	if len(snapshot.Renewals) != len(previous.Renewals) || snapshot.Renewals[0] != previous.Renewals[0] {
+		t.Errorf("Refresh renewals = %+v, want the previous %+v", snapshot.Renewals, previous.Renewals)
+	}
+	if len(snapshot.CostInfos) != len(previous.CostInfos) {
+		t.Errorf("Refresh cost infos = %d, want %d", len(snapshot.CostInfos), len(previous.CostInfos))
+	}
+	if got := productById(snapshot, "3112").ResetEnd; !got.Equal(want) {
+		t.Errorf("product 3112 reset end = %s, want %s", got, want)
+	}
+}
+
+func productById(snapshot *Snapshot, productId string) Product {
+	for _, product := range snapshot.Products {
+		if product.ProductId == productId {
+			return product
+		}
+	}
+	return Product{}
+}
+
+// 首次抓取时成本页面异常, 没有续费信息, 不输出流量重置时间
+func TestRefreshCostFaultWithoutPrevious(t *testing.T) {
+	panel, scraper := newTestScraper(t)
+	panel.SetFault(fakepanel.PageCost, fakepanel.Fault{Status: http.StatusBadGateway})
+	snapshot := scraper.Refresh()
+	if snapshot.Success || len(snapshot.Renewals) != 0 || len(snapshot.Products) != fixtureProducts {
+		t.Fatalf("Refresh = success %v, renewals %d, products %d", snapshot.Success, len(snapshot.Renewals), len(snapshot.Products))
+	}
+	for _, product := range snapshot.Products {
+		if !product.ResetEnd.IsZero() {
+			t.Errorf("product %s reset end = %s, want zero", product.ProductId, product.ResetEnd)
+		}
+	}
+}
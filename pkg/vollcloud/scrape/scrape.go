@@ -0,0 +1,417 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"vollcloud-exporter/pkg/unit/date"
+	"vollcloud-exporter/pkg/unit/url_parse"
+	"vollcloud-exporter/pkg/vollcloud/forecast"
+	"vollcloud-exporter/pkg/vollcloud/grab"
+	vclogin "vollcloud-exporter/pkg/vollcloud/login"
+	"vollcloud-exporter/pkg/vollcloud/metrics"
+)
+
+// Product 单个产品的抓取结果, Success 为 false 时 Stats 无效
+type Product struct {
+	ProductId string
+	Stats     grab.Stats
+	Success   bool
+	// Forecast 流量使用预测, 历史样本不足时为 nil
+	Forecast *forecast.Forecast
+	// ResetStart / ResetEnd 当前流量周期起止时间, 流量在续费日期对应的每月同一天重置; 无续费信息时为零值
+	ResetStart time.Time
+	ResetEnd   time.Time
+}
+
+// Snapshot 一次完整抓取的结果, 生成后不再修改
+type Snapshot struct {
+	Products  []Product
+	Renewals  []grab.RenewalEntry
+	CostInfos []grab.CostInfo // 由 Renewals 展开的付费周期
+	UpdatedAt time.Time
+	Success   bool // 登录及所有页面均抓取解析成功
+}
+
+// Scraper 后台定时抓取单个账号的 vollcloud 页面, 并缓存最近一次的 Snapshot
+type Scraper struct {
+	Account    vclogin.Account
+	HttpClient *http.Client
+	Interval   time.Duration
+
+	mutex    sync.RWMutex
+	snapshot *Snapshot
+	// scrapeLock 后台定时抓取与 /probe 抓取互斥, 共用同一登录会话; 使用 channel 以便等待时可以超时
+	scrapeLock chan struct{}
+
+	loginBackoffUntil time.Time // 出现验证码后暂停登录, 避免频繁请求登录页面
+
+	history *forecast.History // 各产品流量使用历史, 用于预测
+}
+
+func NewScraper(account vclogin.Account) *Scraper {
+	return &Scraper{
+		Account:    account,
+		Interval:   getInterval(),
+		scrapeLock: make(chan struct{}, 1),
+		history:    forecast.NewHistory(getForecastWindow()),
+	}
+}
+
+// Run 立即抓取一次, 之后按 Interval 定时刷新 Snapshot, 不会返回
+func (s *Scraper) Run() {
+	s.Refresh()
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.Refresh()
+	}
+}
+
+// Refresh 执行一次完整抓取并替换缓存的 Snapshot, 返回本次抓取结果.
+// 登录状态、服务列表无法获取或抓取 panic 时保留上一次的 Snapshot, 只将 scrape_success 置 0;
+// 成本页面无法获取时沿用上一次 Snapshot 的续费信息
+func (s *Scraper) Refresh() *Snapshot {
+	return s.RefreshContext(context.Background())
+}
+
+// RefreshContext 同 Refresh, ctx 结束后等待中的抓取直接返回, 进行中及后续的页面请求立即失败,
+// 用于 /probe 按 Prometheus scrape_timeout 限制抓取时长
+func (s *Scraper) RefreshContext(ctx context.Context) *Snapshot {
+	select {
+	case s.scrapeLock <- struct{}{}:
+	case <-ctx.Done():
+		log.Println("Failed Scraper Refresh, wait for the running scrape: ", s.Account.Name, ctx.Err().Error())
+		return &Snapshot{UpdatedAt: time.Now()}
+	}
+	defer func() {
+		<-s.scrapeLock
+	}()
+	start := time.Now()
+	snapshot, err := s.safeScrape(ctx)
+	metrics.ScrapeDurationSeconds.WithLabelValues(s.Account.Name).Set(time.Since(start).Seconds())
+	if err != nil {
+		metrics.ScrapeSuccess.WithLabelValues(s.Account.Name).Set(0)
+		log.Println("Failed Scraper Refresh, keep the previous snapshot, account: ", s.Account.Name, err.Error())
+		return &Snapshot{UpdatedAt: time.Now()}
+	}
+	s.forecast(snapshot, time.Now())
+	if snapshot.Success {
+		metrics.ScrapeSuccess.WithLabelValues(s.Account.Name).Set(1)
+	} else {
+		metrics.ScrapeSuccess.WithLabelValues(s.Account.Name).Set(0)
+	}
+	s.mutex.Lock()
+	s.snapshot = snapshot
+	s.mutex.Unlock()
+	log.Println("Info Scraper Refresh success, account: ", s.Account.Name, "products: ", len(snapshot.Products))
+	return snapshot
+}
+
+// forecast 记录本次抓取的流量样本, 并为每个产品预测流量周期结束时的使用量及流量耗尽时间
+func (s *Scraper) forecast(snapshot *Snapshot, now time.Time) {
+	var productIds []string
+	for i, product := range snapshot.Products {
+		productIds = append(productIds, product.ProductId)
+		if !product.Success {
+			continue
+		}
+		stats := product.Stats
+		if s.history.Add(product.ProductId, forecast.Sample{Time: now, UsedGB: stats.BandwidthUsedGB}) {
+			log.Println("Info forecast bandwidth reset detected, account: ", s.Account.Name, "product_id: ", product.ProductId)
+		}
+		rate, ok := s.history.Rate(product.ProductId)
+		if !ok {
+			continue
+		}
+		totalGB := stats.BandwidthTotalGB
+		if stats.BandwidthUnlimited {
+			totalGB = 0
+		}
+		resetEnd := product.ResetEnd
+		if resetEnd.IsZero() {
+			// 没有续费信息时只按自然月估算预测的截止时间, 不作为流量重置时间输出
+			_, resetEnd = calendarMonth(now)
+		}
+		f := forecast.Project(stats.BandwidthUsedGB, totalGB, rate, now, resetEnd)
+		snapshot.Products[i].Forecast = &f
+	}
+	s.history.Retain(productIds)
+}
+
+// calendarMonth now 所在自然月的起止时间
+func calendarMonth(now time.Time) (time.Time, time.Time) {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+// safeScrape 执行 Scrape, 将 panic 转为 error, 避免后台 goroutine 崩溃导致进程退出
+func (s *Scraper) safeScrape(ctx context.Context) (snapshot *Snapshot, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Failed Scrape panic, account: %s, panic: %v\n%s", s.Account.Name, r, debug.Stack())
+			err = fmt.Errorf("Scrape panic: %v", r)
+		}
+	}()
+	return s.Scrape(ctx)
+}
+
+// Snapshot 返回最近一次抓取结果, 尚未完成首次抓取时返回 nil
+func (s *Scraper) Snapshot() *Snapshot {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.snapshot
+}
+
+// Scrape 检查登录状态, 抓取成本页面、服务列表及所有产品详情页.
+// 用户中心或服务列表无法获取及 ctx 结束时返回 error, 此时没有完整的产品列表, 调用方应保留上一次的 Snapshot;
+// 成本页面或部分产品失败时返回 Success=false 的 Snapshot; ctx 结束后页面请求立即失败 (登录请求不受影响)
+func (s *Scraper) Scrape(ctx context.Context) (*Snapshot, error) {
+	snapshot := &Snapshot{Success: true}
+	defer func() {
+		snapshot.UpdatedAt = time.Now()
+	}()
+	account := s.Account.Name
+	if s.HttpClient == nil {
+		// 启动时优先恢复持久化的会话, 下方 clientarea 校验失败时才重新登录
+		s.HttpClient = s.loadSession()
+	}
+	if s.HttpClient == nil {
+		s.HttpClient = s.login()
+	}
+	httpClient := withContext(*s.HttpClient, ctx)
+	vcClientarea := grab.NewClientarea(httpClient, account)
+	if err := vcClientarea.Get(); err != nil {
+		// 网络或面板异常时不重新登录, 避免频繁请求登录页面
+		return nil, fmt.Errorf("clientarea: %w", err)
+	}
+	if _, err := vcClientarea.IfUserLogin(); errors.Is(err, grab.ErrNotLoggedIn) {
+		log.Println("Failed grab in login, About to sign in again from. account: ", account)
+		s.HttpClient = s.login()
+		httpClient = withContext(*s.HttpClient, ctx)
+	}
+
+	costs := grab.NewCost(httpClient, account)
+	err := costs.GetCost()
+	if err == nil {
+		err = costs.GetRenewalEntries()
+	}
+	if err != nil {
+		// 成本页面暂时异常时沿用上一次的续费信息, 避免流量重置时间等依赖续费日期的指标跳变
+		log.Println("Failed GetCost, keep the previous renewals, account: ", account, err.Error())
+		snapshot.Success = false
+		if previous := s.Snapshot(); previous != nil {
+			snapshot.Renewals = previous.Renewals
+			snapshot.CostInfos = grab.ExpandCostInfos(previous.Renewals, time.Now())
+		}
+	} else {
+		snapshot.Renewals = costs.Entries
+		snapshot.CostInfos = grab.ExpandCostInfos(costs.Entries, time.Now())
+	}
+
+	vsServices := grab.NewServices(httpClient, account)
+	if err := vsServices.Get(); err != nil {
+		return nil, fmt.Errorf("services: %w", err)
+	}
+	if err := vsServices.GetProductIdUrls(); err != nil {
+		return nil, fmt.Errorf("services: %w", err)
+	}
+	idUrls := make(chan string)
+	products := make(chan Product, len(vsServices.IdUrls))
+	var wg sync.WaitGroup
+	for i := 0; i < getConcurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idUrl := range idUrls {
+				products <- scrapeProduct(httpClient, account, idUrl)
+			}
+		}()
+	}
+	for _, idUrl := range vsServices.IdUrls {
+		idUrls <- idUrl
+	}
+	close(idUrls)
+	wg.Wait()
+	close(products)
+	if err := ctx.Err(); err != nil {
+		// 超时导致的失败不代表面板异常, 不发布只有部分产品的结果
+		return nil, fmt.Errorf("products: %w", err)
+	}
+	for product := range products {
+		snapshot.Products = append(snapshot.Products, product)
+		if !product.Success {
+			snapshot.Success = false
+		}
+	}
+	setBandwidthReset(snapshot, time.Now())
+	return snapshot, nil
+}
+
+// setBandwidthReset 根据续费日期计算各产品当前的流量周期; 没有续费信息的产品保持零值, 不输出流量重置相关指标
+func setBandwidthReset(snapshot *Snapshot, now time.Time) {
+	dueDates := map[string]string{}
+	for _, entry := range snapshot.Renewals {
+		if _, ok := dueDates[entry.ProductId]; !ok && len(entry.DueDate) != 0 {
+			dueDates[entry.ProductId] = entry.DueDate
+		}
+	}
+	for i, product := range snapshot.Products {
+		dueDate, ok := dueDates[product.ProductId]
+		if !ok {
+			continue
+		}
+		anchor, err := time.ParseInLocation("2006-01-02", dueDate, now.Location())
+		if err != nil {
+			log.Println("Failed setBandwidthReset, product_id: ", product.ProductId, err.Error())
+			continue
+		}
+		start, end, err := date.CycleMonthly.PeriodAt(anchor, now)
+		if err != nil {
+			log.Println("Failed setBandwidthReset, product_id: ", product.ProductId, err.Error())
+			continue
+		}
+		snapshot.Products[i].ResetStart = start
+		snapshot.Products[i].ResetEnd = end
+	}
+}
+
+// contextTransport 为每个请求附加 ctx, ctx 结束后进行中及后续的请求立即失败
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// withContext 返回请求受 ctx 控制的 http.Client 副本, 共用 cookie jar; ctx 不会结束时原样返回
+func withContext(httpClient http.Client, ctx context.Context) http.Client {
+	if ctx.Done() == nil {
+		return httpClient
+	}
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	httpClient.Transport = contextTransport{ctx: ctx, base: base}
+	return httpClient
+}
+
+// scrapeProduct 抓取单个产品详情页, 可被多个 worker 并发调用.
+// 抓取解析过程中的 panic 只影响当前产品, 返回 Success=false 并记录堆栈
+func scrapeProduct(httpClient http.Client, account, idUrl string) (product Product) {
+	productId, err := url_parse.GetParameId(idUrl, "id")
+	if err != nil {
+		log.Println(err.Error(), productId, idUrl)
+	}
+	product.ProductId = productId
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Failed scrapeProduct panic, account: %s, product_id: %s, panic: %v\n%s", account, productId, r, debug.Stack())
+			product = Product{ProductId: productId}
+		}
+	}()
+
+	vsProductdetails := grab.NewProductdetails(httpClient, account)
+	if err := vsProductdetails.Get(idUrl); err != nil {
+		return product
+	}
+	if err := createStats(productId, vsProductdetails); err != nil {
+		return product
+	}
+	product.Stats = vsProductdetails.Stats
+	product.Success = true
+	return product
+}
+
+// createStats 解析产品详情页, 测试中替换以模拟单个产品解析 panic
+var createStats = func(productId string, productdetails *grab.Productdetails) error {
+	return productdetails.CreateStats()
+}
+
+// login 登录账号并返回携带会话 cookie 的 http.Client, 每个账号独立 cookie jar
+func (s *Scraper) login() *http.Client {
+	vcLogin := vclogin.NewLogin(s.Account)
+	if time.Now().Before(s.loginBackoffUntil) {
+		log.Println("Warn login backoff after captcha, account: ", s.Account.Name, "until: ", s.loginBackoffUntil.Format(time.RFC3339))
+		return vcLogin.HttpClient
+	}
+	_, err := vcLogin.Login()
+	if errors.Is(err, vclogin.ErrCaptchaRequired) {
+		metrics.LoginTotal.WithLabelValues(s.Account.Name, "captcha_required").Inc()
+		s.loginBackoffUntil = time.Now().Add(getCaptchaBackoff())
+		log.Println("Failed grab in login, captcha required, account: ", s.Account.Name)
+	} else if err != nil {
+		metrics.LoginTotal.WithLabelValues(s.Account.Name, "failure").Inc()
+		log.Println("Failed grab in login, account: ", s.Account.Name)
+	} else {
+		metrics.LoginTotal.WithLabelValues(s.Account.Name, "success").Inc()
+		if sessionFile := vclogin.GetSessionFile(s.Account); len(sessionFile) != 0 {
+			if err := vcLogin.SaveSession(sessionFile); err != nil {
+				log.Println("Failed SaveSession, account: ", s.Account.Name, err.Error())
+			}
+		}
+	}
+	return vcLogin.HttpClient
+}
+
+// loadSession 从持久化文件恢复账号会话, 未配置或恢复失败时返回 nil
+func (s *Scraper) loadSession() *http.Client {
+	sessionFile := vclogin.GetSessionFile(s.Account)
+	if len(sessionFile) == 0 {
+		return nil
+	}
+	vcLogin := vclogin.NewLogin(s.Account)
+	if err := vcLogin.LoadSession(sessionFile); err != nil {
+		log.Println("Warn LoadSession, account: ", s.Account.Name, err.Error())
+		return nil
+	}
+	log.Println("Info LoadSession success, account: ", s.Account.Name)
+	return vcLogin.HttpClient
+}
+
+// getForecastWindow 流量预测使用的历史样本时长, 默认 86400 秒
+func getForecastWindow() time.Duration {
+	window := viper.GetInt("vollcloud.forecast.window")
+	if window <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(window) * time.Second
+}
+
+// getConcurrency 产品详情页并发抓取数, 默认 5
+func getConcurrency() int {
+	concurrency := viper.GetInt("vollcloud.concurrency")
+	if concurrency <= 0 {
+		return 5
+	}
+	return concurrency
+}
+
+// getCaptchaBackoff 登录出现验证码后暂停登录的时长, 默认 3600 秒
+func getCaptchaBackoff() time.Duration {
+	backoff := viper.GetInt("vollcloud.login.captcha_backoff")
+	if backoff <= 0 {
+		return 3600 * time.Second
+	}
+	return time.Duration(backoff) * time.Second
+}
+
+// getInterval 后台抓取间隔, 默认 300 秒
+func getInterval() time.Duration {
+	interval := viper.GetInt("vollcloud.interval")
+	if interval <= 0 {
+		return 300 * time.Second
+	}
+	return time.Duration(interval) * time.Second
+}
@@ -0,0 +1,218 @@
+package scrape
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"vollcloud-exporter/pkg/vollcloud/fakepanel"
+	"vollcloud-exporter/pkg/vollcloud/grab"
+	vclogin "vollcloud-exporter/pkg/vollcloud/login"
+)
+
+// fixtureProducts docs/example/services.html 中的产品数量
+const fixtureProducts = 10
+
+// newTestScraper 启动模拟面板并创建指向它的 Scraper
+func newTestScraper(t *testing.T) (*fakepanel.Server, *Scraper) {
+	t.Helper()
+	panel, err := fakepanel.NewServer("../../../docs/example", "user@example.com", "secret")
+	if err != nil {
+		t.Fatalf("fakepanel.NewServer error: %v", err)
+	}
+	t.Cleanup(panel.Close)
+	panel.Configure()
+	viper.Set("vollcloud.timeout", 1)
+	return panel, NewScraper(vclogin.Account{Name: "test", Username: panel.Username, Password: panel.Password})
+}
+
+func refreshOK(t *testing.T, scraper *Scraper) *Snapshot {
+	t.Helper()
+	snapshot := scraper.Refresh()
+	if !snapshot.Success {
+		t.Fatal("Refresh Success = false, want true")
+	}
+	if len(snapshot.Products) != fixtureProducts {
+		t.Fatalf("Refresh products = %d, want %d", len(snapshot.Products), fixtureProducts)
+	}
+	if scraper.Snapshot() != snapshot {
+		t.Fatal("Refresh did not publish the new snapshot")
+	}
+	return snapshot
+}
+
+func TestRefresh(t *testing.T) {
+	panel, scraper := newTestScraper(t)
+	snapshot := refreshOK(t, scraper)
+	for _, product := range snapshot.Products {
+		if !product.Success || product.Stats.BandwidthTotalGB == 0 {
+			t.Errorf("product %s = %+v, want parsed stats", product.ProductId, product)
+		}
+	}
+	if len(snapshot.Renewals) == 0 || len(snapshot.CostInfos) == 0 {
+		t.Errorf("Refresh renewals = %d, cost infos = %d, want both parsed", len(snapshot.Renewals), len(snapshot.CostInfos))
+	}
+	refreshOK(t, scraper)
+	if panel.LoginCount() != 1 {
+		t.Errorf("LoginCount = %d, want 1 (the session is reused)", panel.LoginCount())
+	}
+}
+
+func TestRefreshReloginAfterSessionExpired(t *testing.T) {
+	panel, scraper := newTestScraper(t)
+	refreshOK(t, scraper)
+	panel.ExpireSessions()
+	refreshOK(t, scraper)
+	if panel.LoginCount() != 2 {
+		t.Errorf("LoginCount = %d, want 2", panel.LoginCount())
+	}
+}
+
+// 用户中心或服务列表异常时保留上一次的 Snapshot, 不发布空结果
+func TestRefreshKeepsSnapshotOnFault(t *testing.T) {
+	for _, tt := range []struct {
+		page  string
+		fault fakepanel.Fault
+	}{
+		{fakepanel.PageClientarea, fakepanel.Fault{Status: http.StatusBadGateway}},
+		{fakepanel.PageServices, fakepanel.Fault{Status: http.StatusServiceUnavailable}},
+		{fakepanel.PageServices, fakepanel.Fault{Delay: 2 * time.Second}},
+	} {
+		panel, scraper := newTestScraper(t)
+		previous := refreshOK(t, scraper)
+		panel.SetFault(tt.page, tt.fault)
+		if snapshot := scraper.Refresh(); snapshot.Success {
+			t.Errorf("%s %+v: Refresh Success = true, want false", tt.page, tt.fault)
+		}
+		if scraper.Snapshot() != previous {
+			t.Errorf("%s %+v: cached snapshot was replaced", tt.page, tt.fault)
+		}
+		panel.SetFault(tt.page, fakepanel.Fault{})
+		refreshOK(t, scraper)
+		if panel.LoginCount() != 1 {
+			t.Errorf("%s %+v: LoginCount = %d, want 1 (faults must not trigger re-login)", tt.page, tt.fault, panel.LoginCount())
+		}
+	}
+}
+
+// 产品详情页异常时发布 Success=false 的 Snapshot, 每个产品标记为失败
+func TestRefreshProductFault(t *testing.T) {
+	panel, scraper := newTestScraper(t)
+	panel.SetFault(fakepanel.PageProductdetails, fakepanel.Fault{Status: http.StatusBadGateway})
+	snapshot := scraper.Refresh()
+	if snapshot.Success {
+		t.Fatal("Refresh Success = true, want false")
+	}
+	if len(snapshot.Products) != fixtureProducts {
+		t.Fatalf("Refresh products = %d, want %d", len(snapshot.Products), fixtureProducts)
+	}
+	for _, product := range snapshot.Products {
+		if product.Success {
+			t.Errorf("product %s Success = true, want false", product.ProductId)
+		}
+	}
+}
+
+func TestRefreshSlowPanel(t *testing.T) {
+	panel, scraper := newTestScraper(t)
+	panel.SetFault(fakepanel.PageProductdetails, fakepanel.Fault{Delay: 100 * time.Millisecond})
+	refreshOK(t, scraper)
+}
+
+// 配置会话目录后, 重启的 Scraper 恢复已保存的会话, 不重新登录
+func TestRefreshRestoresSession(t *testing.T) {
+	panel, scraper := newTestScraper(t)
+	viper.Set("vollcloud.session.dir", t.TempDir())
+	t.Cleanup(func() { viper.Set("vollcloud.session.dir", nil) })
+	refreshOK(t, scraper)
+
+	restarted := NewScraper(scraper.Account)
+	refreshOK(t, restarted)
+	if panel.LoginCount() != 1 {
+		t.Errorf("LoginCount = %d, want 1 (the saved session is reused)", panel.LoginCount())
+	}
+}
+
+// ctx 结束后抓取立即返回并保留上一次的 Snapshot, 不发布只有部分产品的结果
+func TestRefreshContextDeadline(t *testing.T) {
+	panel, scraper := newTestScraper(t)
+	previous := refreshOK(t, scraper)
+	panel.SetFault(fakepanel.PageProductdetails, fakepanel.Fault{Delay: 5 * time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if snapshot := scraper.RefreshContext(ctx); snapshot.Success {
+		t.Error("RefreshContext Success = true, want false")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("RefreshContext returned after %s, want shortly after the deadline", elapsed)
+	}
+	if scraper.Snapshot() != previous {
+		t.Error("RefreshContext replaced the cached snapshot after the deadline")
+	}
+	if panel.LoginCount() != 1 {
+		t.Errorf("LoginCount = %d, want 1", panel.LoginCount())
+	}
+}
+
+// 等待进行中的抓取时 ctx 结束, 直接返回失败
+func TestRefreshContextWaitsForRunningScrape(t *testing.T) {
+	panel, scraper := newTestScraper(t)
+	refreshOK(t, scraper)
+	panel.SetFault(fakepanel.PageProductdetails, fakepanel.Fault{Delay: time.Second})
+	done := make(chan struct{})
+	go func() {
+		scraper.Refresh()
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if snapshot := scraper.RefreshContext(ctx); snapshot.Success {
+		t.Error("RefreshContext Success = true, want false")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("RefreshContext returned after %s, want at the deadline", elapsed)
+	}
+	<-done
+}
+
+// 解析单个产品时 panic 只影响该产品, 其它产品正常输出
+func TestRefreshProductPanic(t *testing.T) {
+	_, scraper := newTestScraper(t)
+	target := refreshOK(t, scraper).Products[0]
+	defer func(original func(string, *grab.Productdetails) error) { createStats = original }(createStats)
+	createStats = func(productId string, productdetails *grab.Productdetails) error {
+		if productId == target.ProductId {
+			var stats map[string]string
+			stats["Hostname"] = productdetails.Stats.Hostname // nil map 写入 panic
+		}
+		return productdetails.CreateStats()
+	}
+
+	snapshot := scraper.Refresh()
+	if snapshot.Success {
+		t.Error("Refresh Success = true, want false")
+	}
+	if len(snapshot.Products) != fixtureProducts {
+		t.Fatalf("Refresh products = %d, want %d", len(snapshot.Products), fixtureProducts)
+	}
+	for _, product := range snapshot.Products {
+		if product.ProductId == target.ProductId {
+			if product.Success || product.Stats.BandwidthTotalGB != 0 {
+				t.Errorf("product %s = %+v, want Success=false and empty stats", product.ProductId, product)
+			}
+		} else if !product.Success {
+			t.Errorf("product %s Success = false, want true", product.ProductId)
+		}
+	}
+	if scraper.Snapshot() != snapshot {
+		t.Error("Refresh did not publish the snapshot with the failed product")
+	}
+}
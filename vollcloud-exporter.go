@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -14,143 +20,425 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
-	"vollcloud-exporter/pkg/unit/url_parse"
+	"vollcloud-exporter/pkg/vollcloud/capture"
+	"vollcloud-exporter/pkg/vollcloud/forecast"
 	"vollcloud-exporter/pkg/vollcloud/grab"
 	vclogin "vollcloud-exporter/pkg/vollcloud/login"
+	"vollcloud-exporter/pkg/vollcloud/metrics"
+	"vollcloud-exporter/pkg/vollcloud/scrape"
 )
 
 func init() {
 	pflag.String("address", ":9109", "The address on which to expose the web interface and generated Prometheus metrics.")
 	pflag.String("configfile", "./config/vollcloud-exporter.yaml", "exporter config file")
+	pflag.String("fixtures.dir", "./docs/example", "capture/golden subcommand: fixture page directory")
+	pflag.String("capture.account", "", "capture subcommand: account name to capture, default the first account")
+	pflag.Bool("golden.update", false, "golden subcommand: rewrite golden files from the current parse results")
+	pflag.Bool("cost.legacy", false, "also expose the legacy vollcloud_cost_usd metric with date_start/date_end labels")
 }
 
 const namespace = "vollcloud"
 
 type Exporter struct {
-	HttpClient       *http.Client
+	Scrapers         []*scrape.Scraper
 	NodeOnline       prometheus.GaugeVec
+	ProductInfo      prometheus.GaugeVec
+	MemoryBytes      prometheus.GaugeVec
+	DiskBytes        prometheus.GaugeVec
+	CpuCores         prometheus.GaugeVec
 	BandwidthTotalGB prometheus.GaugeVec
 	BandwidthUsedGB  prometheus.GaugeVec
 	BandwidthFreeGB  prometheus.GaugeVec
 	BandwidthUsage   prometheus.GaugeVec
-	CostUSD          prometheus.GaugeVec
+	// BandwidthProjectedGB / BandwidthExhaustion 按最近流量使用速率的预测
+	BandwidthProjectedGB prometheus.GaugeVec
+	BandwidthExhaustion  prometheus.GaugeVec
+	BandwidthReset       prometheus.GaugeVec
+	BandwidthResetDays   prometheus.GaugeVec
+	BandwidthBurnRatio   prometheus.GaugeVec
+	CostUSD              prometheus.GaugeVec
+	CostPeriodUSD        prometheus.GaugeVec
+	CostDailyRateUSD     prometheus.GaugeVec
+	CostMonthToDate      prometheus.GaugeVec
+	NextRenewal          prometheus.GaugeVec
+	SnapshotAge          prometheus.GaugeVec
+	ProductSuccess       prometheus.GaugeVec
+	// LegacyCost 兼容旧 dashboard, 输出带日期标签的 cost_usd (每天产生新的时间序列)
+	LegacyCost bool
+
+	mutex sync.Mutex
 }
 
-func NewExporter(httpClient http.Client) *Exporter {
+func NewExporter(scrapers []*scrape.Scraper) *Exporter {
 	return &Exporter{
-		HttpClient: &httpClient,
+		Scrapers:   scrapers,
+		LegacyCost: viper.GetBool("cost.legacy"),
 		NodeOnline: *prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "node_online",
 				Help:      "server run status value, Disabled=0 / Online=1",
-			}, []string{"product_id", "ip_address", "hostname", "vm_type", "memory", "disk"}),
+			}, []string{"account", "product_id", "ip_address", "hostname", "vm_type"}),
+		MemoryBytes: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "memory_bytes",
+				Help:      "服务器内存容量 bytes",
+			}, []string{"account", "product_id"}),
+		DiskBytes: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "disk_bytes",
+				Help:      "服务器硬盘容量 bytes",
+			}, []string{"account", "product_id"}),
+		CpuCores: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "cpu_cores",
+				Help:      "服务器 CPU 核心数",
+			}, []string{"account", "product_id"}),
+		ProductInfo: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "product_info",
+				Help:      "产品描述信息, 值固定为 1, 通过标签关联其它指标",
+			}, []string{"account", "product_id", "hostname", "ip_address", "ipv6_address", "extra_ip_addresses", "vm_type", "os_template", "node", "registration_date"}),
 		BandwidthTotalGB: *prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "bandwidth_total_GB",
 				Help:      "宽带流量当月总数 GB",
-			}, []string{"product_id", "ip_address", "hostname"}),
+			}, []string{"account", "product_id", "ip_address", "hostname"}),
 		BandwidthUsedGB: *prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "bandwidth_used_GB",
 				Help:      "宽带流量当月使用总数 GB",
-			}, []string{"product_id", "ip_address", "hostname"}),
+			}, []string{"account", "product_id", "ip_address", "hostname"}),
 		BandwidthFreeGB: *prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "bandwidth_free_GB",
 				Help:      "宽带流量当月剩余总数 GB",
-			}, []string{"product_id", "ip_address", "hostname"}),
+			}, []string{"account", "product_id", "ip_address", "hostname"}),
 		BandwidthUsage: *prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "bandwidth_usage",
 				Help:      "宽带流量使用百分比 %",
-			}, []string{"product_id", "ip_address", "hostname"}),
+			}, []string{"account", "product_id", "ip_address", "hostname"}),
+		BandwidthProjectedGB: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "bandwidth_projected_month_end_GB",
+				Help:      "按最近流量使用速率预测的流量周期结束时使用总数 GB",
+			}, []string{"account", "product_id", "ip_address", "hostname"}),
+		BandwidthExhaustion: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "bandwidth_exhaustion_timestamp_seconds",
+				Help:      "按最近流量使用速率预测的流量耗尽时间 unix timestamp, 流量周期结束前不会耗尽时不输出",
+			}, []string{"account", "product_id", "ip_address", "hostname"}),
+		BandwidthReset: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "bandwidth_reset_timestamp_seconds",
+				Help:      "下次流量重置时间 unix timestamp, 按续费日期每月重置",
+			}, []string{"account", "product_id"}),
+		BandwidthResetDays: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "bandwidth_days_until_reset",
+				Help:      "距离下次流量重置的天数",
+			}, []string{"account", "product_id"}),
+		BandwidthBurnRatio: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "bandwidth_burn_ratio",
+				Help:      "流量使用百分比 / 流量周期已过去百分比, 大于 1 表示按当前进度会超出流量",
+			}, []string{"account", "product_id", "ip_address", "hostname"}),
 		CostUSD: *prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "cost_usd",
 				Help:      "服务成本/USD",
-			}, []string{"product_id", "ip_address", "hostname", "date_start", "date_end", "cost_cycle"}),
+			}, []string{"account", "product_id", "ip_address", "hostname", "date_start", "date_end", "cost_cycle"}),
+		CostPeriodUSD: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "cost_period_usd",
+				Help:      "每个付费周期的续费单价/USD",
+			}, []string{"account", "product_id", "cycle"}),
+		CostDailyRateUSD: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "cost_daily_rate_usd",
+				Help:      "当期账单按实际天数平摊的每日成本/USD",
+			}, []string{"account", "product_id"}),
+		CostMonthToDate: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "cost_month_to_date_usd",
+				Help:      "本月 1 日至今 (含当天) 累计分摊的成本/USD",
+			}, []string{"account", "product_id"}),
+		NextRenewal: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "next_renewal_timestamp_seconds",
+				Help:      "下次续费 (到期) 时间 unix timestamp",
+			}, []string{"account", "product_id"}),
+		SnapshotAge: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "snapshot_age_seconds",
+				Help:      "距离最近一次后台抓取完成的秒数",
+			}, []string{"account"}),
+		ProductSuccess: *prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "product_scrape_success",
+				Help:      "产品详情页抓取解析是否成功, Failed=0 / Success=1",
+			}, []string{"account", "product_id"}),
 	}
 }
 
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	e.NodeOnline.Describe(ch)
+	e.ProductInfo.Describe(ch)
+	e.MemoryBytes.Describe(ch)
+	e.DiskBytes.Describe(ch)
+	e.CpuCores.Describe(ch)
 	e.BandwidthTotalGB.Describe(ch)
 	e.BandwidthFreeGB.Describe(ch)
 	e.BandwidthUsage.Describe(ch)
 	e.BandwidthUsedGB.Describe(ch)
-	e.CostUSD.Describe(ch)
+	e.BandwidthProjectedGB.Describe(ch)
+	e.BandwidthExhaustion.Describe(ch)
+	e.BandwidthReset.Describe(ch)
+	e.BandwidthResetDays.Describe(ch)
+	e.BandwidthBurnRatio.Describe(ch)
+	if e.LegacyCost {
+		e.CostUSD.Describe(ch)
+	}
+	e.CostPeriodUSD.Describe(ch)
+	e.CostDailyRateUSD.Describe(ch)
+	e.CostMonthToDate.Describe(ch)
+	e.NextRenewal.Describe(ch)
+	e.SnapshotAge.Describe(ch)
+	e.ProductSuccess.Describe(ch)
 }
 
+// Collect 只输出后台抓取的 Snapshot, 不会访问 vollcloud 页面
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
 	e.NodeOnline.Reset()
+	e.ProductInfo.Reset()
+	e.MemoryBytes.Reset()
+	e.DiskBytes.Reset()
+	e.CpuCores.Reset()
 	e.BandwidthTotalGB.Reset()
 	e.BandwidthUsedGB.Reset()
 	e.BandwidthFreeGB.Reset()
 	e.BandwidthUsage.Reset()
+	e.BandwidthProjectedGB.Reset()
+	e.BandwidthExhaustion.Reset()
+	e.BandwidthReset.Reset()
+	e.BandwidthResetDays.Reset()
+	e.BandwidthBurnRatio.Reset()
 	e.CostUSD.Reset()
+	e.CostPeriodUSD.Reset()
+	e.CostDailyRateUSD.Reset()
+	e.CostMonthToDate.Reset()
+	e.NextRenewal.Reset()
+	e.SnapshotAge.Reset()
+	e.ProductSuccess.Reset()
 
-	httpClient := *e.HttpClient
-	vcClientarea := grab.NewClientarea(httpClient)
-	vcClientarea.Get()
-	_, err := vcClientarea.IfUserLogin()
-	if err != nil {
-		log.Println("Failed grab in login, About to sign in again from.")
-		httpClient = *loginGetClient()
-		e.HttpClient = &httpClient
+	for _, scraper := range e.Scrapers {
+		e.collectSnapshot(scraper.Account.Name, scraper.Snapshot())
 	}
 
-	costs := grab.NewCost(httpClient)
-	costErr := costs.GetCost()
-	if costErr != nil {
-		log.Println("Failed GetCost: ", costErr.Error())
+	e.NodeOnline.Collect(ch)
+	e.ProductInfo.Collect(ch)
+	e.MemoryBytes.Collect(ch)
+	e.DiskBytes.Collect(ch)
+	e.CpuCores.Collect(ch)
+	e.BandwidthTotalGB.Collect(ch)
+	e.BandwidthUsedGB.Collect(ch)
+	e.BandwidthFreeGB.Collect(ch)
+	e.BandwidthUsage.Collect(ch)
+	e.BandwidthProjectedGB.Collect(ch)
+	e.BandwidthExhaustion.Collect(ch)
+	e.BandwidthReset.Collect(ch)
+	e.BandwidthResetDays.Collect(ch)
+	e.BandwidthBurnRatio.Collect(ch)
+	if e.LegacyCost {
+		e.CostUSD.Collect(ch)
 	}
+	e.CostPeriodUSD.Collect(ch)
+	e.CostDailyRateUSD.Collect(ch)
+	e.CostMonthToDate.Collect(ch)
+	e.NextRenewal.Collect(ch)
+	e.SnapshotAge.Collect(ch)
+	e.ProductSuccess.Collect(ch)
+}
 
-	vsServices := grab.NewServices(httpClient)
-	vsServices.Get()
-	vsServices.GetProductIdUrls()
-	idUrls := vsServices.IdUrls
-	for _, idUrl := range idUrls {
-		vsProductdetails := grab.NewProductdetails(httpClient)
-		if err := vsProductdetails.Get(idUrl); err != nil {
+// collectSnapshot 将单个账号的 Snapshot 写入指标
+func (e *Exporter) collectSnapshot(account string, snapshot *scrape.Snapshot) {
+	if snapshot == nil {
+		log.Println("Warn Collect snapshot is not ready, account: ", account)
+		return
+	}
+	for _, product := range snapshot.Products {
+		productId := product.ProductId
+		if !product.ResetEnd.IsZero() {
+			e.BandwidthReset.WithLabelValues(account, productId).Set(float64(product.ResetEnd.Unix()))
+			e.BandwidthResetDays.WithLabelValues(account, productId).Set(time.Until(product.ResetEnd).Hours() / 24)
+		}
+		if !product.Success {
+			e.ProductSuccess.WithLabelValues(account, productId).Set(0)
 			continue
 		}
-		productId, err := url_parse.GetParameId(idUrl, "id")
-		if err != nil {
-			log.Println(err.Error(), productId, idUrl)
+		e.ProductSuccess.WithLabelValues(account, productId).Set(1)
+		stats := product.Stats
+		e.NodeOnline.WithLabelValues(account, productId, stats.IpAddress, stats.Hostname, stats.Type).Set(stats.Status)
+		if stats.MemoryBytes > 0 {
+			e.MemoryBytes.WithLabelValues(account, productId).Set(stats.MemoryBytes)
 		}
-		if err := vsProductdetails.CreateStats(); err != nil {
-			continue
+		if stats.DiskBytes > 0 {
+			e.DiskBytes.WithLabelValues(account, productId).Set(stats.DiskBytes)
+		}
+		if stats.CpuCores > 0 {
+			e.CpuCores.WithLabelValues(account, productId).Set(float64(stats.CpuCores))
 		}
-		e.NodeOnline.WithLabelValues(productId, vsProductdetails.Stats.IpAddress, vsProductdetails.Stats.Hostname, vsProductdetails.Stats.Type, vsProductdetails.Stats.Memory, vsProductdetails.Stats.Disk).Set(vsProductdetails.Stats.Status)
-		e.BandwidthTotalGB.WithLabelValues(productId, vsProductdetails.Stats.IpAddress, vsProductdetails.Stats.Hostname).Set(vsProductdetails.Stats.BandwidthTotalGB)
-		e.BandwidthUsedGB.WithLabelValues(productId, vsProductdetails.Stats.IpAddress, vsProductdetails.Stats.Hostname).Set(vsProductdetails.Stats.BandwidthUsedGB)
-		e.BandwidthFreeGB.WithLabelValues(productId, vsProductdetails.Stats.IpAddress, vsProductdetails.Stats.Hostname).Set(vsProductdetails.Stats.BandwidthFreeGB)
-		e.BandwidthUsage.WithLabelValues(productId, vsProductdetails.Stats.IpAddress, vsProductdetails.Stats.Hostname).Set(vsProductdetails.Stats.BandwidthUsage)
-		if costErr != nil {
+		e.ProductInfo.WithLabelValues(account, productId, stats.Hostname, stats.IpAddress, stats.Ipv6Address, strings.Join(stats.ExtraIpAddresses, ","),
+			stats.Type, stats.OsTemplate, stats.Node, stats.RegistrationDate).Set(1)
+		e.BandwidthUsedGB.WithLabelValues(account, productId, stats.IpAddress, stats.Hostname).Set(stats.BandwidthUsedGB)
+		// 不限流量套餐没有总量, 不输出总量/剩余/百分比
+		if !stats.BandwidthUnlimited {
+			e.BandwidthTotalGB.WithLabelValues(account, productId, stats.IpAddress, stats.Hostname).Set(stats.BandwidthTotalGB)
+			e.BandwidthFreeGB.WithLabelValues(account, productId, stats.IpAddress, stats.Hostname).Set(stats.BandwidthFreeGB)
+			e.BandwidthUsage.WithLabelValues(account, productId, stats.IpAddress, stats.Hostname).Set(stats.BandwidthUsage)
+			if ratio, ok := forecast.BurnRatio(stats.BandwidthUsage, product.ResetStart, product.ResetEnd, time.Now()); ok {
+				e.BandwidthBurnRatio.WithLabelValues(account, productId, stats.IpAddress, stats.Hostname).Set(ratio)
+			}
+		}
+		if f := product.Forecast; f != nil {
+			e.BandwidthProjectedGB.WithLabelValues(account, productId, stats.IpAddress, stats.Hostname).Set(f.ProjectedGB)
+			if !f.ExhaustionTime.IsZero() {
+				e.BandwidthExhaustion.WithLabelValues(account, productId, stats.IpAddress, stats.Hostname).Set(float64(f.ExhaustionTime.Unix()))
+			}
+		}
+		if !e.LegacyCost {
 			continue
 		}
-		costs.GetCostInfos()
-		for _, cost := range costs.CostInfos {
+		for _, cost := range snapshot.CostInfos {
 			if cost.ProductId == productId {
 				for _, cost := range grab.SplitCostCycle(cost) {
-					e.CostUSD.WithLabelValues(productId, vsProductdetails.Stats.IpAddress, vsProductdetails.Stats.Hostname, cost.DateStart, cost.DateEnd, cost.CostCycle).Set(cost.BlendedCostUSD)
+					e.CostUSD.WithLabelValues(account, productId, stats.IpAddress, stats.Hostname, cost.DateStart, cost.DateEnd, cost.CostCycle).Set(cost.BlendedCostUSD)
 				}
 			}
 		}
 	}
+	e.collectCost(account, snapshot)
+	e.SnapshotAge.WithLabelValues(account).Set(time.Since(snapshot.UpdatedAt).Seconds())
+}
 
-	e.NodeOnline.Collect(ch)
-	e.BandwidthTotalGB.Collect(ch)
-	e.BandwidthUsedGB.Collect(ch)
-	e.BandwidthFreeGB.Collect(ch)
-	e.BandwidthUsage.Collect(ch)
-	e.CostUSD.Collect(ch)
+// collectCost 输出续费页面的成本指标, 标签不含日期, 时间序列数量稳定
+func (e *Exporter) collectCost(account string, snapshot *scrape.Snapshot) {
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Format("2006-01-02")
+	tomorrow := now.AddDate(0, 0, 1).Format("2006-01-02")
+	for _, entry := range snapshot.Renewals {
+		productId := entry.ProductId
+		e.CostPeriodUSD.WithLabelValues(account, productId, string(entry.Cycle)).Set(entry.PriceUSD)
+		if dueDate, err := time.Parse("2006-01-02", entry.DueDate); err == nil && entry.Cycle.Recurring() {
+			e.NextRenewal.WithLabelValues(account, productId).Set(float64(dueDate.Unix()))
+		}
+		if current, ok := grab.CurrentCostInfo(snapshot.CostInfos, productId, now); ok {
+			if rate, err := grab.DailyRateUSD(current); err == nil {
+				e.CostDailyRateUSD.WithLabelValues(account, productId).Set(rate)
+			}
+		}
+		var monthToDate float64
+		for _, cost := range snapshot.CostInfos {
+			if cost.ProductId != productId {
+				continue
+			}
+			if usd, err := grab.AccruedUSD(cost, monthStart, tomorrow); err == nil {
+				monthToDate += usd
+			}
+		}
+		e.CostMonthToDate.WithLabelValues(account, productId).Set(monthToDate)
+	}
+}
+
+// probe 按 account 参数实时抓取单个账号 (复用已登录会话), 使用独立 Registry 只返回该账号指标;
+// 抓取时长受 Prometheus scrape_timeout 限制, 超时时返回上一次的结果及 probe_success=0
+func probe(scrapers []*scrape.Scraper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account := r.URL.Query().Get("account")
+		var scraper *scrape.Scraper
+		for _, s := range scrapers {
+			if s.Account.Name == account {
+				scraper = s
+			}
+		}
+		if scraper == nil {
+			http.Error(w, fmt.Sprintf("Unknown account %q", account), http.StatusBadRequest)
+			return
+		}
+
+		probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "probe_success",
+			Help:      "本次 probe 抓取是否全部成功, Failed=0 / Success=1",
+		})
+		probeDuration := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "probe_duration_seconds",
+			Help:      "本次 probe 抓取耗时 second",
+		})
+		ctx := r.Context()
+		if timeout, ok := probeTimeout(r); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		start := time.Now()
+		// 超时后 Refresh 保留上一次的 Snapshot, 仍输出缓存的指标, probe_success 为 0
+		if snapshot := scraper.RefreshContext(ctx); snapshot.Success && ctx.Err() == nil {
+			probeSuccess.Set(1)
+		}
+		probeDuration.Set(time.Since(start).Seconds())
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(NewExporter([]*scrape.Scraper{scraper}), probeSuccess, probeDuration)
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
+
+// probeTimeoutOffset 从 Prometheus scrape_timeout 中预留给输出指标及网络传输的时间
+const probeTimeoutOffset = 500 * time.Millisecond
+
+// probeTimeout 根据 Prometheus 请求头 X-Prometheus-Scrape-Timeout-Seconds 计算 probe 抓取时限, 没有该请求头时不限制
+func probeTimeout(r *http.Request) (time.Duration, bool) {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if len(header) == 0 {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		log.Println("Warn probe invalid X-Prometheus-Scrape-Timeout-Seconds: ", header)
+		return 0, false
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > 2*probeTimeoutOffset {
+		timeout -= probeTimeoutOffset
+	} else {
+		timeout /= 2
+	}
+	return timeout, true
 }
 
 func reloadConfig(w http.ResponseWriter, _ *http.Request) {
@@ -183,13 +471,38 @@ func open(uri string) error {
 	return cmd.Start()
 }
 
-func loginGetClient() *http.Client {
-	vcLogin := vclogin.NewLogin()
-	_, err := vcLogin.Login()
+// runCapture capture 子命令: 登录账号抓取页面, 脱敏后保存为 fixture
+func runCapture(accounts []vclogin.Account) {
+	account := accounts[0]
+	if name := viper.GetString("capture.account"); len(name) != 0 {
+		found := false
+		for _, a := range accounts {
+			if a.Name == name {
+				account, found = a, true
+			}
+		}
+		if !found {
+			log.Fatal("Fatal error capture unknown account: ", name)
+		}
+	}
+	if err := capture.Capture(account, viper.GetString("fixtures.dir")); err != nil {
+		log.Fatal("Fatal error capture: ", err.Error())
+	}
+}
+
+// runGolden golden 子命令: 解析 fixture 并与 golden 文件对比, 有差异时以非 0 状态退出
+func runGolden() {
+	diffs, err := capture.CheckGolden(viper.GetString("fixtures.dir"), viper.GetBool("golden.update"))
 	if err != nil {
-		log.Println("Failed grab in login")
+		log.Fatal("Fatal error golden: ", err.Error())
+	}
+	for _, diff := range diffs {
+		fmt.Println(diff)
+	}
+	if len(diffs) != 0 {
+		os.Exit(1)
 	}
-	return vcLogin.HttpClient
+	fmt.Println("golden ok")
 }
 
 func main() {
@@ -197,6 +510,10 @@ func main() {
 	if err := viper.BindPFlags(pflag.CommandLine); err != nil {
 		log.Fatal("Fatal error BindPFlags: %w", err.Error())
 	}
+	if pflag.Arg(0) == "golden" {
+		runGolden()
+		return
+	}
 	fmt.Println("load config file ", viper.GetString("configfile"))
 	viper.SetConfigType("yaml")
 	viper.SetConfigFile(viper.GetString("configfile"))
@@ -204,14 +521,29 @@ func main() {
 		panic(fmt.Errorf("Fatal error config file: %w \n", err))
 	}
 
-	httpClient := *loginGetClient()
+	accounts, err := vclogin.GetAccounts()
+	if err != nil {
+		log.Fatal("Fatal error accounts: ", err.Error())
+	}
+	if pflag.Arg(0) == "capture" {
+		runCapture(accounts)
+		return
+	}
+	var scrapers []*scrape.Scraper
+	for _, account := range accounts {
+		scraper := scrape.NewScraper(account)
+		go scraper.Run()
+		scrapers = append(scrapers, scraper)
+	}
 
-	prometheus.MustRegister(NewExporter(httpClient))
+	prometheus.MustRegister(NewExporter(scrapers))
+	prometheus.MustRegister(metrics.Collectors()...)
 
 	// http server
 	listenAddress := viper.GetString("address")
 	fmt.Printf("http server start, address %s/metrics\n", listenAddress)
 	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/probe", probe(scrapers))
 	http.HandleFunc("/reload", reloadConfig)
 	go func() {
 		time.Sleep(time.Second)
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+
+	"vollcloud-exporter/pkg/vollcloud/fakepanel"
+	vclogin "vollcloud-exporter/pkg/vollcloud/login"
+	"vollcloud-exporter/pkg/vollcloud/scrape"
+)
+
+// newTestScraper 启动模拟面板并创建指向它的 Scraper
+func newTestScraper(t *testing.T) (*fakepanel.Server, *scrape.Scraper) {
+	t.Helper()
+	panel, err := fakepanel.NewServer("./docs/example", "user@example.com", "secret")
+	if err != nil {
+		t.Fatalf("fakepanel.NewServer error: %v", err)
+	}
+	t.Cleanup(panel.Close)
+	panel.Configure()
+	viper.Set("vollcloud.timeout", 1)
+	return panel, scrape.NewScraper(vclogin.Account{Name: "test", Username: panel.Username, Password: panel.Password})
+}
+
+func TestProbeTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"10", 9500 * time.Millisecond, true},
+		{"2.5", 2 * time.Second, true},
+		{"0.8", 400 * time.Millisecond, true},
+		{"0", 0, false},
+		{"-1", 0, false},
+		{"NaN", 0, false},
+		{"+Inf", 0, false},
+		{"10s", 0, false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/probe?account=test", nil)
+		if len(tt.header) != 0 {
+			r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tt.header)
+		}
+		if got, ok := probeTimeout(r); got != tt.want || ok != tt.ok {
+			t.Errorf("probeTimeout(%q) = %s, %v, want %s, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// serveProbe 请求 /probe 并返回响应及耗时
+func serveProbe(t *testing.T, scrapers []*scrape.Scraper, account string, timeout string) (*httptest.ResponseRecorder, time.Duration) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/probe?account="+account, nil)
+	if len(timeout) != 0 {
+		r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", timeout)
+	}
+	w := httptest.NewRecorder()
+	start := time.Now()
+	probe(scrapers)(w, r)
+	return w, time.Since(start)
+}
+
+func TestProbe(t *testing.T) {
+	_, scraper := newTestScraper(t)
+	w, _ := serveProbe(t, []*scrape.Scraper{scraper}, "test", "10")
+	if w.Code != http.StatusOK {
+		t.Fatalf("probe status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"vollcloud_probe_success 1", `vollcloud_node_online{account="test"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("probe body does not contain %q", want)
+		}
+	}
+
+	if w, _ := serveProbe(t, []*scrape.Scraper{scraper}, "unknown", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("probe unknown account status = %d, want 400", w.Code)
+	}
+}
+
+// 面板响应超过 scrape_timeout 时按时返回缓存的指标及 probe_success=0
+func TestProbeDeadline(t *testing.T) {
+	panel, scraper := newTestScraper(t)
+	if snapshot := scraper.Refresh(); !snapshot.Success {
+		t.Fatal("Refresh Success = false, want true")
+	}
+	panel.SetFault(fakepanel.PageProductdetails, fakepanel.Fault{Delay: 5 * time.Second})
+
+	w, elapsed := serveProbe(t, []*scrape.Scraper{scraper}, "test", "1.5")
+	if elapsed > 1500*time.Millisecond {
+		t.Errorf("probe returned after %s, want within the scrape timeout", elapsed)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"vollcloud_probe_success 0", `vollcloud_node_online{account="test"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("probe body does not contain %q", want)
+		}
+	}
+}
+
+// 样例中续费记录的产品 ID 均已脱敏为 3333, 将第一条关联到服务列表中的产品 3112
+const (
+	renewalProductId = "3112"
+	noRenewalProduct = "3266"
+)
+
+// newTestExporter 抓取模拟面板并注册 Exporter; 产品 3112 的续费到期时间改为 dueDate
+func newTestExporter(t *testing.T, dueDate time.Time) (*fakepanel.Server, *scrape.Scraper, *prometheus.Registry) {
+	t.Helper()
+	panel, scraper := newTestScraper(t)
+	cost, err := os.ReadFile("./docs/example/cost.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := strings.Replace(string(cost), "sid=3333", "sid="+renewalProductId, 1)
+	page = strings.Replace(page, "2023-08-11", dueDate.Format("2006-01-02"), 1)
+	panel.SetFixture(fakepanel.PageCost, []byte(page))
+	if snapshot := scraper.Refresh(); !snapshot.Success {
+		t.Fatal("Refresh Success = false, want true")
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewExporter([]*scrape.Scraper{scraper}))
+	return panel, scraper, registry
+}
+
+// gatherSeries 按指标名收集各时间序列的标签及数值
+func gatherSeries(t *testing.T, registry *prometheus.Registry, name string) []map[string]string {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather error: %v", err)
+	}
+	var series []map[string]string
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{"": strconv.FormatFloat(metric.GetGauge().GetValue(), 'g', -1, 64)}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			series = append(series, labels)
+		}
+	}
+	return series
+}
+
+// productValue 指标中产品的数值, 空字符串 key 为数值
+func productValue(t *testing.T, registry *prometheus.Registry, name, productId string) (float64, bool) {
+	t.Helper()
+	for _, labels := range gatherSeries(t, registry, name) {
+		if labels["product_id"] == productId {
+			value, err := strconv.ParseFloat(labels[""], 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+func countSeries(t *testing.T, registry *prometheus.Registry, name string) int {
+	t.Helper()
+	count, err := testutil.GatherAndCount(registry, name)
+	if err != nil {
+		t.Fatalf("GatherAndCount %s error: %v", name, err)
+	}
+	return count
+}
+
+func TestExporterProducts(t *testing.T) {
+	panel, scraper, registry := newTestExporter(t, time.Now().AddDate(0, 2, -3))
+
+	for _, name := range []string{"vollcloud_product_scrape_success", "vollcloud_node_online", "vollcloud_product_info",
+		"vollcloud_memory_bytes", "vollcloud_disk_bytes", "vollcloud_cpu_cores", "vollcloud_bandwidth_total_GB"} {
+		if count := countSeries(t, registry, name); count != 10 {
+			t.Errorf("%s series = %d, want 10", name, count)
+		}
+	}
+	for _, labels := range gatherSeries(t, registry, "vollcloud_product_scrape_success") {
+		if labels[""] != "1" {
+			t.Errorf("product_scrape_success %v, want 1", labels)
+		}
+	}
+	// node_online 只保留稳定的标签, 内存及硬盘由 memory_bytes / disk_bytes 输出
+	for _, labels := range gatherSeries(t, registry, "vollcloud_node_online") {
+		want := map[string]string{"": "1", "account": "test", "product_id": labels["product_id"],
+			"ip_address": "3.3.3.3", "hostname": "cn-hk-33.43.229.153.128", "vm_type": "kvm"}
+		if !equalLabels(labels, want) {
+			t.Errorf("node_online %v, want %v", labels, want)
+		}
+	}
+
+	// product_info 携带描述标签, 容量类指标按产品输出数值
+	for _, labels := range gatherSeries(t, registry, "vollcloud_product_info") {
+		want := map[string]string{"": "1", "account": "test", "product_id": labels["product_id"],
+			"hostname": "cn-hk-33.43.229.153.128", "ip_address": "3.3.3.3", "ipv6_address": "", "extra_ip_addresses": "",
+			"vm_type": "kvm", "os_template": "", "node": "HongKong", "registration_date": ""}
+		if !equalLabels(labels, want) {
+			t.Errorf("product_info %v, want %v", labels, want)
+		}
+	}
+	for name, want := range map[string]float64{
+		"vollcloud_memory_bytes": 512 * 1024 * 1024,
+		"vollcloud_disk_bytes":   10 * 1024 * 1024 * 1024,
+		"vollcloud_cpu_cores":    1,
+	} {
+		if value, ok := productValue(t, registry, name, renewalProductId); !ok || value != want {
+			t.Errorf("%s = %v, %v, want %v", name, value, ok, want)
+		}
+	}
+
+	// 会话过期后 Refresh 重新登录, Collect 输出新的结果
+	panel.ExpireSessions()
+	if snapshot := scraper.Refresh(); !snapshot.Success {
+		t.Fatal("Refresh after session expired Success = false, want true")
+	}
+	if panel.LoginCount() != 2 {
+		t.Errorf("LoginCount = %d, want 2", panel.LoginCount())
+	}
+	if count := countSeries(t, registry, "vollcloud_node_online"); count != 10 {
+		t.Errorf("node_online series after re-login = %d, want 10", count)
+	}
+	if age, ok := productValue(t, registry, "vollcloud_snapshot_age_seconds", ""); !ok || age > 5 {
+		t.Errorf("snapshot_age_seconds = %v, %v, want a fresh snapshot", age, ok)
+	}
+}
+
+// 产品详情页异常时只输出 product_scrape_success=0, 不输出该产品的其它指标
+func TestExporterProductFault(t *testing.T) {
+	panel, scraper, registry := newTestExporter(t, time.Now().AddDate(0, 2, -3))
+	panel.SetFault(fakepanel.PageProductdetails, fakepanel.Fault{Status: http.StatusBadGateway})
+	scraper.Refresh()
+	for _, labels := range gatherSeries(t, registry, "vollcloud_product_scrape_success") {
+		if labels[""] != "0" {
+			t.Errorf("product_scrape_success %v, want 0", labels)
+		}
+	}
+	for _, name := range []string{"vollcloud_node_online", "vollcloud_product_info", "vollcloud_memory_bytes"} {
+		if count := countSeries(t, registry, name); count != 0 {
+			t.Errorf("%s series = %d, want 0", name, count)
+		}
+	}
+}
+
+func TestExporterCost(t *testing.T) {
+	now := time.Now()
+	dueDate := now.AddDate(0, 2, -3)
+	_, scraper, registry := newTestExporter(t, dueDate)
+
+	if value, ok := productValue(t, registry, "vollcloud_cost_period_usd", renewalProductId); !ok || value != 149 {
+		t.Errorf("cost_period_usd = %v, %v, want 149", value, ok)
+	}
+	wantDue, _ := time.Parse("2006-01-02", dueDate.Format("2006-01-02"))
+	if value, ok := productValue(t, registry, "vollcloud_next_renewal_timestamp_seconds", renewalProductId); !ok || value != float64(wantDue.Unix()) {
+		t.Errorf("next_renewal_timestamp_seconds = %v, %v, want %d", value, ok, wantDue.Unix())
+	}
+	rate, ok := productValue(t, registry, "vollcloud_cost_daily_rate_usd", renewalProductId)
+	if !ok || rate < 149.0/366 || rate > 149.0/365 {
+		t.Errorf("cost_daily_rate_usd = %v, %v, want 149 USD per year", rate, ok)
+	}
+	if value, ok := productValue(t, registry, "vollcloud_cost_month_to_date_usd", renewalProductId); !ok || value <= 0 || value > rate*float64(now.Day())+0.01 {
+		t.Errorf("cost_month_to_date_usd = %v, %v, want at most %d days at %v", value, ok, now.Day(), rate)
+	}
+	// 标签不含日期, 默认不输出旧版 cost_usd
+	for _, labels := range gatherSeries(t, registry, "vollcloud_cost_period_usd") {
+		if _, ok := labels["date_start"]; ok {
+			t.Errorf("cost_period_usd %v has a date label", labels)
+		}
+	}
+	if count := countSeries(t, registry, "vollcloud_cost_usd"); count != 0 {
+		t.Errorf("cost_usd series = %d, want 0 without --cost.legacy", count)
+	}
+
+	viper.Set("cost.legacy", true)
+	t.Cleanup(func() { viper.Set("cost.legacy", nil) })
+	legacy := prometheus.NewRegistry()
+	legacy.MustRegister(NewExporter([]*scrape.Scraper{scraper}))
+	series := gatherSeries(t, legacy, "vollcloud_cost_usd")
+	if len(series) == 0 {
+		t.Fatal("cost_usd series = 0, want the legacy series with --cost.legacy")
+	}
+	for _, labels := range series {
+		if labels["product_id"] != renewalProductId || len(labels["date_start"]) == 0 || len(labels["date_end"]) == 0 {
+			t.Errorf("cost_usd %v, want product %s with date labels", labels, renewalProductId)
+		}
+	}
+}
+
+func TestExporterBandwidthReset(t *testing.T) {
+	now := time.Now()
+	_, _, registry := newTestExporter(t, now.AddDate(0, 2, -3))
+
+	reset, ok := productValue(t, registry, "vollcloud_bandwidth_reset_timestamp_seconds", renewalProductId)
+	if !ok || reset <= float64(now.Unix()) || reset > float64(now.AddDate(0, 1, 1).Unix()) {
+		t.Errorf("bandwidth_reset_timestamp_seconds = %v, %v, want within the next month", reset, ok)
+	}
+	if days, ok := productValue(t, registry, "vollcloud_bandwidth_days_until_reset", renewalProductId); !ok || days <= 0 || days > 31 {
+		t.Errorf("bandwidth_days_until_reset = %v, %v, want 0-31", days, ok)
+	}
+	ratio, ok := productValue(t, registry, "vollcloud_bandwidth_burn_ratio", renewalProductId)
+	if !ok || ratio <= 0 || math.IsInf(ratio, 0) {
+		t.Errorf("bandwidth_burn_ratio = %v, %v, want a positive ratio", ratio, ok)
+	}
+	// 没有续费信息的产品不输出流量重置及消耗速率
+	for _, name := range []string{"vollcloud_bandwidth_reset_timestamp_seconds", "vollcloud_bandwidth_days_until_reset", "vollcloud_bandwidth_burn_ratio"} {
+		if _, ok := productValue(t, registry, name, noRenewalProduct); ok {
+			t.Errorf("%s has product %s without renewal", name, noRenewalProduct)
+		}
+		if count := countSeries(t, registry, name); count != 1 {
+			t.Errorf("%s series = %d, want 1", name, count)
+		}
+	}
+}
+
+func equalLabels(got, want map[string]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for name, value := range want {
+		if got[name] != value {
+			return false
+		}
+	}
+	return true
+}